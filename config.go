@@ -1,14 +1,25 @@
 package config
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
 	"reflect"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/creasty/defaults"
 	"github.com/go-playground/validator"
 	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
+	"github.com/subosito/gotenv"
 )
 
 const (
@@ -20,79 +31,1296 @@ const (
 
 	// defaultFileType is the default configuration file type.
 	defaultFileType = "yaml"
+
+	// defaultTagName is the default struct tag used for field mapping.
+	defaultTagName = "env"
 )
 
-// Config is a wrapper around viper.
-type Config struct {
-	v *viper.Viper
+var (
+	// fileDefaultsMu guards the file defaults changed by SetDefaults.
+	fileDefaultsMu sync.RWMutex
+
+	// filePathDefault, fileNameDefault and fileTypeDefault are the config file
+	// settings used by New when no options change them.
+	filePathDefault = defaultFilePath
+	fileNameDefault = defaultFileName
+	fileTypeDefault = defaultFileType
+)
+
+// SetDefaults changes the config file path, name without extension and type
+// used by New when no options change them, e.g. `./config`, `app` and `json`
+// for the apps always using the same convention. It only affects the Configs
+// created afterwards.
+func SetDefaults(path, name, fileType string) {
+	fileDefaultsMu.Lock()
+	defer fileDefaultsMu.Unlock()
+
+	filePathDefault = path
+	fileNameDefault = name
+	fileTypeDefault = fileType
+}
+
+// overlayFile is a config file merged on top of the configuration.
+type overlayFile struct {
+	path     string
+	fileType string
+}
+
+// Config is a wrapper around viper, it is safe for concurrent use.
+type Config struct {
+	v *viper.Viper
+
+	// mu guards the viper instance and the state read and written with it.
+	mu sync.RWMutex
+
+	// filePaths are the configuration file paths, searched in order.
+	filePaths []string
+
+	// fileName is the configuration file name without extension.
+	fileName string
+
+	// fileType is the configuration file type.
+	fileType string
+
+	// configFileUsed is the config file read, empty if none was found.
+	configFileUsed string
+
+	// requireFile reports a missing config file as an error.
+	requireFile bool
+
+	// environmentVar is the environment variable holding the environment name,
+	// the environment specific config file is merged on top of the base one.
+	environmentVar string
+
+	// configFile is the full configuration file path, if set it is used instead
+	// of filePaths, fileName and fileType.
+	configFile string
+
+	// configFileType is the type of configFile, if empty it is inferred from
+	// the extension.
+	configFileType string
+
+	// mergeFiles are the configuration file names merged in order, if set they
+	// are used instead of fileName.
+	mergeFiles []string
+
+	// overlayFiles are the config files merged on top of the configuration, in
+	// order, each one read with the parser of its type.
+	overlayFiles []overlayFile
+
+	// reader is read instead of the config file, if set.
+	reader io.Reader
+
+	// fsys is the file system the fsFileName config file is read from, if set.
+	fsys fs.FS
+
+	// fsFileName is the name of the config file read from fsys.
+	fsFileName string
+
+	// sources are the sources read in priority order instead of the config
+	// file.
+	sources []Source
+
+	// customViper is the viper instance set with WithViper, used instead of a
+	// new one.
+	customViper *viper.Viper
+
+	// settingsMap is the map read as the configuration instead of the config
+	// file.
+	settingsMap map[string]interface{}
+
+	// remoteFetcher fetches the configuration from the remote key/value
+	// store instead of the config file.
+	remoteFetcher RemoteFetcher
+
+	// remoteData is the content last fetched from the remote store.
+	remoteData []byte
+
+	// readerData is the content read from the reader, kept so the configuration
+	// can be read again on Reset.
+	readerData []byte
+
+	// sourceOptions are the names of the options applied that set where the
+	// configuration is read from, e.g. WithConfigFile, to report conflicts.
+	sourceOptions []string
+
+	// dotEnvFiles are the dotenv files loaded into the environment before
+	// reading the configuration.
+	dotEnvFiles []string
+
+	// defaultsFile is the config file read as the default values, the lowest
+	// precedence layer.
+	defaultsFile string
+
+	// duplicateKeyDetection makes reading a yaml config file with duplicate
+	// keys an error.
+	duplicateKeyDetection bool
+
+	// envPrefix is the prefix environment variables must have to be read.
+	envPrefix string
+
+	// envKeyReplacer maps the configuration keys to environment variable names.
+	envKeyReplacer *strings.Replacer
+
+	// globalEnvPropagation copies the top-level values into the nested sections
+	// that don't define them.
+	globalEnvPropagation bool
+
+	// fileEnv reads the keys from the files named by the `_FILE` environment
+	// variables.
+	fileEnv bool
+
+	// keyNamingStrategy converts the keys of the settings before decoding.
+	keyNamingStrategy func(string) string
+
+	// keyCases holds the keys of the config files not written in lowercase by
+	// their lowercased dotted path, for the key naming strategy.
+	keyCases map[string]string
+
+	// envExpansion expands the environment variables referenced by the string
+	// values, e.g. `${HOME}/logs`, an undefined variable is an error if
+	// strictEnvExpansion is set.
+	envExpansion       bool
+	strictEnvExpansion bool
+
+	// emptyAsUnset drops the keys holding an empty string before decoding.
+	emptyAsUnset bool
+
+	// strictDecoding reports the keys that don't match any field as an error.
+	strictDecoding bool
+
+	// tagName is the struct tag used for field mapping.
+	tagName string
+
+	// timeLayout is the layout used to parse time.Time fields.
+	timeLayout string
+
+	// sliceSeparator is the separator used to split strings into slices.
+	sliceSeparator string
+
+	// sliceEnvExtend extends the slices with the environment variables of the
+	// indexes out of range instead of failing.
+	sliceEnvExtend bool
+
+	// base64Bytes decodes the []byte fields from base64.
+	base64Bytes bool
+
+	// truthyStrings and falsyStrings are the strings decoded into true and
+	// false respectively, if set they are the only ones accepted.
+	truthyStrings []string
+	falsyStrings  []string
+
+	// defaultsFirst sets the default values before decoding instead of after.
+	defaultsFirst bool
+
+	// withoutDefaults skips setting the default values.
+	withoutDefaults bool
+
+	// collectAllErrors makes Unmarshal return all the errors of its stages
+	// joined instead of the first one.
+	collectAllErrors bool
+
+	// types are the factories of the registered config types by name.
+	types map[string]func() interface{}
+
+	// decodeHooks are the user decode hooks run after the built-in ones.
+	decodeHooks []mapstructure.DecodeHookFunc
+
+	// observer is called with the duration of every loading stage.
+	observer func(stage string, d time.Duration)
+
+	// postProcess are the functions transforming the config structures after
+	// the default values are set, before validating them.
+	postProcess []func(config interface{}) error
+
+	// redactKeys are the names of the sensitive keys redacted by RedactedSettings.
+	redactKeys []string
+
+	// validate is the validator used to validate the config structures.
+	validate *validator.Validate
+
+	// errorFormatter formats the failure of every field in the validation
+	// errors, if nil the default message is used.
+	errorFormatter func(validator.FieldError) string
+
+	// validationTagName is the struct tag naming the fields in the validation
+	// errors, if empty the validator names are kept.
+	validationTagName string
+
+	// deprecatedKeys are the keys reported by Unmarshal as deprecated.
+	deprecatedKeys []deprecatedKey
+
+	// warnings are the warnings recorded by the last Unmarshal.
+	warnings []string
+
+	// warningsMu guards the warnings, which are recorded by the concurrent
+	// calls to Unmarshal.
+	warningsMu sync.Mutex
+
+	// keyPrefix is the dotted key of the section of a configuration returned
+	// by Sub, e.g. `database.`, the keys are read from the environment
+	// variables with it.
+	keyPrefix string
+
+	// boundEnvKeys are the keys of the struct fields bound to their
+	// environment variables in the viper instance, with the variable names.
+	boundEnvKeys map[string]string
+
+	// flagSets are the flag sets bound with BindPFlags.
+	flagSets []*pflag.FlagSet
+
+	// overrides are the values set with Set, kept so they are set again when
+	// the watched config file changes.
+	overrides map[string]interface{}
+
+	// bindings are the flags and aliases bound to the viper instance, kept so
+	// they are bound again on Reset.
+	bindings []func(*viper.Viper) error
+
+	// err is the error produced while reading the configuration file.
+	err error
+
+	// watchOnce ensures the configuration file is watched only once.
+	watchOnce sync.Once
+
+	// reloadMu serializes the reloads triggered by the watcher.
+	reloadMu sync.Mutex
+
+	// onConfigChange is called every time the watched config file changes,
+	// with the error produced while reading it, if any.
+	onConfigChange func(error)
+
+	// done is closed by Stop to stop the polling.
+	done chan struct{}
+
+	// frozen makes the changes of the settings fail with ErrFrozen.
+	frozen bool
+
+	// stopOnce ensures done is closed only once.
+	stopOnce sync.Once
+}
+
+// New creates a new Config.
+func New(opts ...Option) *Config {
+	validate := newValidator()
+
+	fileDefaultsMu.RLock()
+	c := &Config{
+		filePaths: []string{filePathDefault},
+		fileName:  fileNameDefault,
+		fileType:  fileTypeDefault,
+
+		envKeyReplacer:    strings.NewReplacer(".", "_"),
+		tagName:           defaultTagName,
+		timeLayout:        time.RFC3339,
+		sliceSeparator:    ",",
+		redactKeys:        defaultRedactKeys,
+		validate:          validate,
+		validationTagName: defaultTagName,
+		done:              make(chan struct{}),
+	}
+	fileDefaultsMu.RUnlock()
+
+	// apply options
+	ApplyOptions(c, opts)
+
+	// Name the fields in the validation errors as they are named in the
+	// configuration, e.g. `host` instead of `Host`. The validator set with
+	// WithValidator is left as given
+	if c.validate == validate && c.validationTagName != "" {
+		c.validate.RegisterTagNameFunc(c.fieldName)
+	}
+
+	// Try to read the configuration
+	c.v = c.newViper()
+	c.err = c.load()
+
+	return c
+}
+
+// Load creates a Config with the options and decodes the configuration into
+// config, returning the error reading the config file, if any, or the
+// Unmarshal error.
+func Load(config interface{}, opts ...Option) error {
+	c := New(opts...)
+	if err := c.Err(); err != nil {
+		return err
+	}
+
+	return c.Unmarshal(config)
+}
+
+// load reads the configuration into the viper instance from the configured
+// source, e.g. the config file, and merges the overlay files on top of it.
+func (c *Config) load() error {
+	defer c.observe("read", time.Now())
+
+	if err := c.checkOptions(); err != nil {
+		return err
+	}
+
+	if err := c.loadDotEnvFiles(); err != nil {
+		return err
+	}
+
+	if err := c.loadDefaultsFile(); err != nil {
+		return err
+	}
+
+	// The instance set with WithViper already holds its configuration
+	if c.customViper != nil {
+		return c.mergeOverlayFiles()
+	}
+
+	// Only the HCL files are collapsed, not the remote configuration
+	read := c.readConfig
+	if c.configType() == "hcl" && c.remoteFetcher == nil {
+		read = c.readHCLConfig
+	}
+
+	if err := read(); err != nil {
+		return err
+	}
+
+	return c.mergeOverlayFiles()
+}
+
+// readConfig reads the configuration from the configured source.
+func (c *Config) readConfig() error {
+	// Read the given config file, its type is inferred from the extension
+	// unless it is set
+	if c.configFile != "" {
+		c.v.SetConfigFile(c.configFile)
+		if c.configFileType != "" {
+			if err := c.setConfigType(c.configFileType); err != nil {
+				return err
+			}
+		}
+		c.configFileUsed = c.configFile
+
+		if err := c.checkDuplicateKeysFile(c.configFile, c.configFileType); err != nil {
+			return err
+		}
+
+		return c.v.ReadInConfig()
+	}
+
+	// Read the configuration from the file system, its type is inferred from
+	// the extension
+	if c.fsys != nil {
+		data, err := fs.ReadFile(c.fsys, c.fsFileName)
+		if err != nil {
+			return err
+		}
+
+		fileType := strings.TrimPrefix(path.Ext(c.fsFileName), ".")
+		if err := c.setConfigType(fileType); err != nil {
+			return err
+		}
+
+		if err := c.checkDuplicateKeys(c.fsFileName, data, fileType); err != nil {
+			return err
+		}
+
+		return c.v.ReadConfig(bytes.NewReader(data))
+	}
+
+	if err := c.setConfigType(c.fileType); err != nil {
+		return err
+	}
+
+	// Read the configuration from the source chain instead of the config file
+	if c.sources != nil {
+		return c.loadSources()
+	}
+
+	// Read the configuration from the settings map instead of the config
+	// file, viper lowercases the keys of the maps it merges so they are copied
+	if c.settingsMap != nil {
+		return c.v.MergeConfigMap(copySettings(c.settingsMap))
+	}
+
+	// Read the configuration from the remote key/value store instead of the
+	// config file, the content has the configured file type
+	if c.remoteFetcher != nil {
+		data, err := c.remoteFetcher.Fetch()
+		if err != nil {
+			return err
+		}
+		c.remoteData = data
+		c.recordKeyCases(data, c.fileType)
+
+		return c.v.ReadConfig(bytes.NewReader(data))
+	}
+
+	// Read the configuration from the reader instead of the config file
+	if c.reader != nil {
+		if c.readerData == nil {
+			data, err := io.ReadAll(c.reader)
+			if err != nil {
+				return err
+			}
+			c.readerData = data
+		}
+
+		if err := c.checkDuplicateKeys("reader", c.readerData, c.fileType); err != nil {
+			return err
+		}
+
+		return c.v.ReadConfig(bytes.NewReader(c.readerData))
+	}
+
+	// Set the config file
+	for _, filePath := range c.filePaths {
+		c.v.AddConfigPath(filePath)
+	}
+
+	// Try to read the config files, the later files are merged on top of the
+	// previous ones. A missing file is not an error since the configuration can
+	// come entirely from environment variables, unless the first file is required
+	for i, fileName := range c.fileNames() {
+		c.v.SetConfigName(fileName)
+
+		read := c.v.MergeInConfig
+		if i == 0 {
+			read = c.v.ReadInConfig
+		}
+
+		if err := read(); err != nil {
+			if _, ok := err.(viper.ConfigFileNotFoundError); !ok || (i == 0 && c.requireFile) {
+				return err
+			}
+		} else if err := c.checkDuplicateKeysFile(c.v.ConfigFileUsed(), ""); err != nil {
+			return err
+		}
+
+		// Keep the base file, reading the next ones resets it in viper
+		if i == 0 {
+			c.configFileUsed = c.v.ConfigFileUsed()
+		}
+	}
+
+	return nil
+}
+
+// loadDotEnvFiles sets the environment variables of the dotenv files that are
+// not set yet, the missing files are skipped.
+func (c *Config) loadDotEnvFiles() error {
+	for _, file := range c.dotEnvFiles {
+		env, err := gotenv.Read(file)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		for key, value := range env {
+			if _, ok := os.LookupEnv(key); ok {
+				continue
+			}
+
+			if err := os.Setenv(key, value); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// loadDefaultsFile sets the values of the defaults file, if any, as the
+// default values of the keys, so the config file and the environment variables
+// override them.
+func (c *Config) loadDefaultsFile() error {
+	if c.defaultsFile == "" {
+		return nil
+	}
+
+	if err := c.checkDuplicateKeysFile(c.defaultsFile, ""); err != nil {
+		return err
+	}
+
+	v := viper.New()
+	v.SetConfigFile(c.defaultsFile)
+	if err := v.ReadInConfig(); err != nil {
+		return err
+	}
+
+	for _, key := range v.AllKeys() {
+		c.v.SetDefault(key, v.Get(key))
+	}
+
+	return nil
+}
+
+// copySettings returns a copy of the settings and of their nested sections.
+func copySettings(settings map[string]interface{}) map[string]interface{} {
+	cp := make(map[string]interface{}, len(settings))
+	for key, value := range settings {
+		if section, ok := value.(map[string]interface{}); ok {
+			value = copySettings(section)
+		}
+		cp[key] = value
+	}
+
+	return cp
+}
+
+// mergeOverlayFiles reads every overlay file with the parser of its type and
+// merges it on top of the configuration, in the order they were added.
+func (c *Config) mergeOverlayFiles() error {
+	for _, file := range c.overlayFiles {
+		if err := c.checkDuplicateKeysFile(file.path, file.fileType); err != nil {
+			return err
+		}
+
+		v := viper.New()
+		v.SetConfigFile(file.path)
+		if file.fileType != "" {
+			v.SetConfigType(file.fileType)
+		}
+		if err := v.ReadInConfig(); err != nil {
+			return err
+		}
+
+		if err := c.v.MergeConfigMap(v.AllSettings()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// newViper creates a viper instance reading the environment variables.
+func (c *Config) newViper() *viper.Viper {
+	// The instance set with WithViper is used as it is configured
+	if c.customViper != nil {
+		return c.customViper
+	}
+
+	v := viper.New()
+
+	// Namespace the environment variables and map nested keys such as
+	// `database.host` to `DATABASE_HOST`
+	if c.envPrefix != "" {
+		v.SetEnvPrefix(c.envPrefix)
+	}
+	v.SetEnvKeyReplacer(c.envKeyReplacer)
+
+	// Enable VIPER to read Environment Variables, the ones of a section are
+	// only read through the keys bound with their full name
+	if c.keyPrefix == "" {
+		v.AutomaticEnv()
+	}
+
+	return v
+}
+
+// checkOptions returns an error if two of the options applied set where the
+// configuration is read from in conflicting ways, e.g. WithConfigFile and
+// WithFileName, instead of silently ignoring one of them.
+func (c *Config) checkOptions() error {
+	for i, a := range c.sourceOptions {
+		for _, b := range c.sourceOptions[i+1:] {
+			if conflictingOptions(a, b) || conflictingOptions(b, a) {
+				return fmt.Errorf("conflicting options %s and %s, only one of them can set where the configuration is read from", a, b)
+			}
+		}
+	}
+
+	return nil
+}
+
+// conflictingOptions reports whether the source option a conflicts with the
+// option b. The sources conflict with each other and with the options of the
+// searched config files, the sources inferring the file type also conflict
+// with WithFileType.
+func conflictingOptions(a, b string) bool {
+	switch a {
+	case "WithConfigFile", "WithDotEnv", "WithReader", "WithFS", "WithRemoteProvider", "WithRemoteFetcher", "WithSettings", "WithSourceChain", "WithViper":
+	default:
+		return false
+	}
+
+	switch b {
+	case "WithConfigFile", "WithDotEnv", "WithReader", "WithFS", "WithRemoteProvider", "WithRemoteFetcher", "WithSettings", "WithSourceChain", "WithViper":
+		return a != b
+	case "WithFilePath", "WithFilePaths", "WithFileName", "WithMergeFiles", "WithEnvironmentFile":
+		return true
+	case "WithFileType":
+		return a != "WithRemoteProvider" && a != "WithRemoteFetcher"
+	}
+
+	return false
+}
+
+// fileNames returns the configuration file names to read in order.
+func (c *Config) fileNames() []string {
+	fileNames := []string{c.fileName}
+	if len(c.mergeFiles) > 0 {
+		fileNames = append([]string(nil), c.mergeFiles...)
+	}
+
+	// The environment specific file goes last so it overrides the base ones
+	if c.environmentVar != "" {
+		if env := os.Getenv(c.environmentVar); env != "" {
+			fileNames = append(fileNames, c.fileName+"."+env)
+		}
+	}
+
+	return fileNames
+}
+
+// setConfigType sets the type viper parses the configuration with, rejecting
+// the file types viper can't parse instead of failing to read.
+func (c *Config) setConfigType(fileType string) error {
+	if !isSupportedFileType(fileType) {
+		return viper.UnsupportedConfigError(fileType)
+	}
+	c.v.SetConfigType(fileType)
+
+	return nil
+}
+
+// isSupportedFileType reports whether viper can parse the file type.
+func isSupportedFileType(fileType string) bool {
+	for _, ext := range viper.SupportedExts {
+		if fileType == ext {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Err returns the error produced while reading the configuration file, if any.
+// A missing configuration file is not reported as an error unless it is
+// required with WithRequireFile, in which case it is a
+// viper.ConfigFileNotFoundError.
+func (c *Config) Err() error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.err
+}
+
+// ConfigFileUsed returns the path of the config file read, or an empty string
+// if none was found and the configuration comes only from the environment. When
+// several files are merged it is the first one.
+func (c *Config) ConfigFileUsed() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.configFileUsed
+}
+
+// Reset discards the current settings, including the ones set by Set, and
+// reads the configuration again from the environment variables and the config
+// file using the same options, bound flags and aliases.
+func (c *Config) Reset() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.frozen {
+		return ErrFrozen
+	}
+
+	c.overrides = nil
+
+	return c.reset()
+}
+
+// reread reads the configuration again like Reset but keeps the current
+// settings if it can't be read, e.g. when the config file is being written.
+// The configuration is read without holding the lock, the current settings
+// are only locked to be replaced.
+func (c *Config) reread() error {
+	next, err := c.next()
+	if err != nil {
+		return err
+	}
+
+	return c.swap(next)
+}
+
+// reset reads the configuration again into a new viper instance.
+func (c *Config) reset() error {
+	c.v = c.newViper()
+	c.configFileUsed = ""
+	c.boundEnvKeys = nil
+	c.keyCases = nil
+	if c.err = c.load(); c.err != nil {
+		return c.err
+	}
+
+	for _, bind := range c.bindings {
+		if err := bind(c.v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// bind runs the binding on the viper instance and keeps it for Reset.
+func (c *Config) bind(binding func(*viper.Viper) error) error {
+	if err := binding(c.v); err != nil {
+		return err
+	}
+
+	c.bindings = append(c.bindings, binding)
+
+	return nil
+}
+
+// Set sets the value of the key, it takes precedence over the environment
+// variables and the config file, the flags set in the command line take
+// precedence over it. Keys use viper's dotted notation for nested values. The
+// value is kept when the watched config file changes. It returns ErrFrozen if
+// the configuration is frozen.
+func (c *Config) Set(key string, value interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.frozen {
+		return ErrFrozen
+	}
+
+	key = strings.ToLower(key)
+	if c.overrides == nil {
+		c.overrides = make(map[string]interface{})
+	}
+	c.overrides[key] = value
+	c.v.Set(key, value)
+
+	return nil
+}
+
+// Freeze prevents any further change of the settings, e.g. once the
+// configuration is loaded at startup. Set, Reset, BindPFlags and RegisterAlias
+// then return ErrFrozen, and so do the reloads of the watched configuration,
+// which keeps its current settings. The clones are not frozen.
+func (c *Config) Freeze() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.frozen = true
+}
+
+// Clone returns an independent copy of the configuration, holding a copy of the
+// current settings, including the ones set by Set, and the same options, bound
+// flags and aliases. Setting values on the copy doesn't change the original.
+// The validator, including the custom validations, is shared. An error copying
+// the settings or binding the flags again is reported by Err of the copy, like
+// the error reading the original configuration.
+func (c *Config) Clone() *Config {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	clone := c.copyOptions()
+	clone.configFileUsed = c.configFileUsed
+	clone.remoteData = c.remoteData
+	clone.keyCases = c.keyCases
+	clone.customViper = nil
+	clone.err = c.err
+
+	for key, value := range c.overrides {
+		if clone.overrides == nil {
+			clone.overrides = make(map[string]interface{})
+		}
+		clone.overrides[key] = value
+	}
+
+	// Copy the settings into the config layer of the copy, the bound flags
+	// and aliases are bound again on top of them and the values set by Set
+	// are set again so they keep their precedence
+	clone.v = clone.newViper()
+	clone.v.SetConfigType(c.fileType)
+	if err := clone.v.MergeConfigMap(c.v.AllSettings()); err != nil && clone.err == nil {
+		clone.err = err
+	}
+	for _, bind := range clone.bindings {
+		if err := bind(clone.v); err != nil && clone.err == nil {
+			clone.err = err
+		}
+	}
+	for key, value := range clone.overrides {
+		clone.v.Set(key, value)
+	}
+
+	return clone
+}
+
+// copyOptions returns a new Config with the options of the configuration and
+// no settings, the caller must hold the lock.
+func (c *Config) copyOptions() *Config {
+	return &Config{
+		filePaths:             append([]string(nil), c.filePaths...),
+		fileName:              c.fileName,
+		fileType:              c.fileType,
+		requireFile:           c.requireFile,
+		environmentVar:        c.environmentVar,
+		configFile:            c.configFile,
+		configFileType:        c.configFileType,
+		dotEnvFiles:           append([]string(nil), c.dotEnvFiles...),
+		mergeFiles:            append([]string(nil), c.mergeFiles...),
+		overlayFiles:          append([]overlayFile(nil), c.overlayFiles...),
+		reader:                c.reader,
+		fsys:                  c.fsys,
+		fsFileName:            c.fsFileName,
+		sources:               append([]Source(nil), c.sources...),
+		settingsMap:           c.settingsMap,
+		remoteFetcher:         c.remoteFetcher,
+		readerData:            c.readerData,
+		sourceOptions:         append([]string(nil), c.sourceOptions...),
+		defaultsFile:          c.defaultsFile,
+		duplicateKeyDetection: c.duplicateKeyDetection,
+		envPrefix:             c.envPrefix,
+		envKeyReplacer:        c.envKeyReplacer,
+		globalEnvPropagation:  c.globalEnvPropagation,
+		keyNamingStrategy:     c.keyNamingStrategy,
+		envExpansion:          c.envExpansion,
+		strictEnvExpansion:    c.strictEnvExpansion,
+		emptyAsUnset:          c.emptyAsUnset,
+		fileEnv:               c.fileEnv,
+		strictDecoding:        c.strictDecoding,
+		tagName:               c.tagName,
+		timeLayout:            c.timeLayout,
+		sliceSeparator:        c.sliceSeparator,
+		sliceEnvExtend:        c.sliceEnvExtend,
+		base64Bytes:           c.base64Bytes,
+		truthyStrings:         c.truthyStrings,
+		falsyStrings:          c.falsyStrings,
+		defaultsFirst:         c.defaultsFirst,
+		withoutDefaults:       c.withoutDefaults,
+		types:                 c.types,
+		collectAllErrors:      c.collectAllErrors,
+		decodeHooks:           append([]mapstructure.DecodeHookFunc(nil), c.decodeHooks...),
+		postProcess:           append([]func(interface{}) error(nil), c.postProcess...),
+		redactKeys:            c.redactKeys,
+		validate:              c.validate,
+		validationTagName:     c.validationTagName,
+		errorFormatter:        c.errorFormatter,
+		observer:              c.observer,
+		deprecatedKeys:        append([]deprecatedKey(nil), c.deprecatedKeys...),
+		keyPrefix:             c.keyPrefix,
+		customViper:           c.customViper,
+		flagSets:              append([]*pflag.FlagSet(nil), c.flagSets...),
+		bindings:              append([]func(*viper.Viper) error(nil), c.bindings...),
+		done:                  make(chan struct{}),
+	}
+}
+
+// Sub returns a configuration holding only the section of the key, e.g. to
+// decode the `database` section into its own structure, or nil if the key is
+// not a section. The environment variables are still read with the full key,
+// e.g. `DATABASE_HOST` for `host`, and the decoding and validation options are
+// kept. The section is a snapshot, it can't be read again with Reset nor
+// watched.
+func (c *Config) Sub(key string) *Config {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	v := c.subViper(key)
+	if v == nil {
+		return nil
+	}
+
+	return c.sub(key, v)
+}
+
+// UnmarshalKey decodes only the section of the key into config, like Unmarshal
+// on the configuration returned by Sub, so the decode hooks, the default values
+// and the validation apply. A missing section is decoded as an empty one, so
+// the default values are set and the required fields are reported.
+func (c *Config) UnmarshalKey(key string, config interface{}) error {
+	c.mu.RLock()
+	v := c.subViper(key)
+	if v == nil {
+		v = viper.New()
+	}
+	sub := c.sub(key, v)
+	c.mu.RUnlock()
+
+	return sub.Unmarshal(config)
+}
 
-	// filePath is the configuration file path.
-	filePath string
+// subViper returns a viper instance holding the section of the key, or nil if
+// the key is not a section. The flags set in the command line take precedence
+// over Set like in the settings.
+func (c *Config) subViper(key string) *viper.Viper {
+	v := c.v.Sub(key)
+	if v == nil {
+		return nil
+	}
 
-	// fileName is the configuration file name without extension.
-	fileName string
+	prefix := strings.ToLower(key) + "."
+	for k := range c.overrides {
+		if !strings.HasPrefix(k, prefix) {
+			continue
+		}
 
-	// fileType is the configuration file type.
-	fileType string
+		if flag := c.changedFlag(k); flag != nil {
+			v.Set(strings.TrimPrefix(k, prefix), flagValue(k, flag))
+		}
+	}
+
+	return v
 }
 
-// New creates a new Config.
-func New(opts ...Option) *Config {
-	c := &Config{
-		v:        viper.New(),
-		filePath: defaultFilePath,
-		fileName: defaultFileName,
-		fileType: defaultFileType,
+// sub returns a configuration holding the section of the key read by v.
+func (c *Config) sub(key string, v *viper.Viper) *Config {
+	return &Config{
+		v:                    v,
+		keyPrefix:            c.keyPrefix + strings.ToLower(key) + ".",
+		envPrefix:            c.envPrefix,
+		envKeyReplacer:       c.envKeyReplacer,
+		globalEnvPropagation: c.globalEnvPropagation,
+		keyNamingStrategy:    c.keyNamingStrategy,
+		envExpansion:         c.envExpansion,
+		strictEnvExpansion:   c.strictEnvExpansion,
+		emptyAsUnset:         c.emptyAsUnset,
+		fileEnv:              c.fileEnv,
+		strictDecoding:       c.strictDecoding,
+		tagName:              c.tagName,
+		timeLayout:           c.timeLayout,
+		sliceSeparator:       c.sliceSeparator,
+		sliceEnvExtend:       c.sliceEnvExtend,
+		base64Bytes:          c.base64Bytes,
+		truthyStrings:        c.truthyStrings,
+		falsyStrings:         c.falsyStrings,
+		defaultsFirst:        c.defaultsFirst,
+		withoutDefaults:      c.withoutDefaults,
+		types:                c.types,
+		collectAllErrors:     c.collectAllErrors,
+		decodeHooks:          c.decodeHooks,
+		postProcess:          c.postProcess,
+		redactKeys:           c.redactKeys,
+		validate:             c.validate,
+		validationTagName:    c.validationTagName,
+		errorFormatter:       c.errorFormatter,
+		observer:             c.observer,
+		done:                 make(chan struct{}),
 	}
+}
 
-	// apply options
-	ApplyOptions(c, opts)
+// WriteConfigAs writes the merged settings to the file, overwriting it if it
+// exists. The file type is taken from the extension, or the configured file
+// type if the file has none. The settings are written as they are, including
+// the sensitive values, e.g. passwords.
+func (c *Config) WriteConfigAs(path string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	// Set the config file
-	c.v.AddConfigPath(c.filePath)
-	c.v.SetConfigName(c.fileName)
-	c.v.SetConfigType(c.fileType)
+	return c.v.WriteConfigAs(path)
+}
+
+// BindPFlags binds the flags to the configuration keys with the same name, the
+// flags set in the command line take precedence over Set, the environment
+// variables and the config file.
+func (c *Config) BindPFlags(set *pflag.FlagSet) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	// Enable VIPER to read Environment Variables
-	c.v.AutomaticEnv()
+	if c.frozen {
+		return ErrFrozen
+	}
 
-	// Try to read the config file
-	c.v.ReadInConfig()
+	c.flagSets = append(c.flagSets, set)
 
-	return c
+	return c.bind(func(v *viper.Viper) error {
+		return v.BindPFlags(set)
+	})
+}
+
+// changedFlag returns the flag bound to the key, if it is set in the command
+// line.
+func (c *Config) changedFlag(key string) *pflag.Flag {
+	var changed *pflag.Flag
+	for _, set := range c.flagSets {
+		set.VisitAll(func(flag *pflag.Flag) {
+			if flag.Changed && strings.EqualFold(flag.Name, key) {
+				changed = flag
+			}
+		})
+	}
+
+	return changed
+}
+
+// flagValue returns the value of the flag converted to its type, like viper
+// reads it.
+func flagValue(key string, flag *pflag.Flag) interface{} {
+	v := viper.New()
+	v.BindPFlag(key, flag)
+
+	return v.Get(key)
+}
+
+// applyFlags sets the keys set with Set to the value of their flag, if it is
+// set in the command line, since viper gives Set the precedence.
+func (c *Config) applyFlags(settings map[string]interface{}) {
+	for key := range c.overrides {
+		if flag := c.changedFlag(key); flag != nil {
+			setKey(settings, key, flagValue(key, flag))
+		}
+	}
+}
+
+// overridden reports whether the key is set by a flag, Set or an environment
+// variable, the sources taking precedence over the config file.
+func (c *Config) overridden(key string) bool {
+	if _, ok := c.overrides[key]; ok || c.changedFlag(key) != nil {
+		return true
+	}
+
+	name, ok := c.boundEnvKeys[key]
+	if !ok {
+		if c.keyPrefix != "" {
+			return false
+		}
+		name = c.envName(key)
+	}
+
+	return os.Getenv(c.envKeyReplacer.Replace(name)) != ""
+}
+
+// RegisterAlias makes the alias another name for the key, reading either of
+// them returns the same value. The key is also read from the environment
+// variable of the alias, e.g. `port` from `LEGACY_PORT`, the key's own
+// environment variable wins if both are set. It returns ErrFrozen if the
+// configuration is frozen.
+func (c *Config) RegisterAlias(alias, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.frozen {
+		return ErrFrozen
+	}
+
+	return c.bind(func(v *viper.Viper) error {
+		v.RegisterAlias(alias, key)
+
+		return v.BindEnv(key, c.envName(key), c.envName(alias))
+	})
+}
+
+// envName returns the name of the environment variable the key is read from,
+// before the env key replacer is applied.
+func (c *Config) envName(key string) string {
+	if c.envPrefix != "" {
+		key = c.envPrefix + "_" + key
+	}
+
+	return strings.ToUpper(key)
 }
 
 // Unmarshal reads the configuration from the environment variables and the config file.
 func (c *Config) Unmarshal(config interface{}) error {
-	// Get all settings from Viper (from both env and the file) and apply global env settings
-	allSettings := applyGlobalEnvSettings(c.v.AllSettings())
+	return c.UnmarshalContext(context.Background(), config)
+}
+
+// MustUnmarshal is like Unmarshal but panics if the configuration can't be
+// decoded, e.g. in the application startup.
+func (c *Config) MustUnmarshal(config interface{}) {
+	if err := c.Unmarshal(config); err != nil {
+		panic(err)
+	}
+}
 
-	// Decode settings into the provided config structure
-	if err := decodeConfig(allSettings, config); err != nil {
+// UnmarshalContext is like Unmarshal but returns early with the context error
+// if the context is done before any of the decoding, defaults, post-processing
+// and validation stages.
+func (c *Config) UnmarshalContext(ctx context.Context, config interface{}) error {
+	if err := checkConfig(config); err != nil {
 		return err
 	}
 
-	// Use Viper's Unmarshal to handle environment variables with the custom DecodeHook
-	if err := c.v.Unmarshal(config, viper.DecodeHook(mapstructureDecodeHook(config))); err != nil {
+	if err := ctx.Err(); err != nil {
 		return err
 	}
 
-	// Validate required fields using go-playground/validator
-	if err := validateConfig(config); err != nil {
+	start := time.Now()
+	c.bindEnvs(config)
+	c.observe("env-bind", start)
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	// Warn about the deprecated keys still in use
+	c.checkDeprecatedKeys(c.v.AllSettings())
+
+	// Set the default values first so the decoded values take precedence,
+	// including the ones explicitly set to their zero value
+	var errs []error
+	if c.defaultsFirst && !c.withoutDefaults {
+		if err := c.collect(&errs, c.setDefaults(config)); err != nil {
+			return err
+		}
+	}
+
+	if err := c.collect(&errs, c.decode(ctx, config)); err != nil {
 		return err
 	}
 
 	// Set default values for any missing fields
+	if !c.defaultsFirst && !c.withoutDefaults {
+		if err := c.collect(&errs, c.setDefaults(config)); err != nil {
+			return err
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// Transform the values before validating them
+	for _, postProcess := range c.postProcess {
+		if err := c.collect(&errs, postProcess(config)); err != nil {
+			return err
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// Validate required fields using go-playground/validator
+	start = time.Now()
+	err := c.validateConfig(config)
+	c.observe("validate", start)
+	if err := c.collect(&errs, err); err != nil {
+		return err
+	}
+
+	return joinErrors(errs)
+}
+
+// joinErrors returns the single error of errs as it is, so it can be compared
+// directly, or all of them joined.
+func joinErrors(errs []error) error {
+	if len(errs) == 1 {
+		return errs[0]
+	}
+
+	return errors.Join(errs...)
+}
+
+// collect returns err so the caller stops, unless all the errors are
+// collected with WithCollectAllErrors, in which case err is added to errs.
+func (c *Config) collect(errs *[]error, err error) error {
+	if err == nil || !c.collectAllErrors {
+		return err
+	}
+
+	*errs = append(*errs, err)
+
+	return nil
+}
+
+// setDefaults sets the default values of the config structure from the
+// `default` tags.
+func (c *Config) setDefaults(config interface{}) error {
+	defer c.observe("defaults", time.Now())
+
 	return defaults.Set(config)
 }
 
-// decodeConfig decodes the provided settings map into the given config structure.
-func decodeConfig(settings map[string]interface{}, config interface{}) error {
+// observe calls the observer set with WithObserver, if any, with the duration
+// of the stage started at start.
+func (c *Config) observe(stage string, start time.Time) {
+	if c.observer != nil {
+		c.observer(stage, time.Since(start))
+	}
+}
+
+// DecodeOnly decodes the configuration like Unmarshal but neither sets the
+// default values nor validates it, so the fields not provided by the config
+// file or the environment variables keep their zero value. It is meant for the
+// tools inspecting the configuration.
+func (c *Config) DecodeOnly(config interface{}) error {
+	if err := checkConfig(config); err != nil {
+		return err
+	}
+
+	start := time.Now()
+	c.bindEnvs(config)
+	c.observe("env-bind", start)
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.decode(context.Background(), config)
+}
+
+// decode decodes the settings into the config structure, returning early with
+// the context error if the context is done between the decoding passes.
+func (c *Config) decode(ctx context.Context, config interface{}) error {
+	defer c.observe("decode", time.Now())
+
+	// In strict mode check the settings before the global env settings are
+	// applied, otherwise the propagated keys would be reported as unknown
+	var errs []error
+	if c.strictDecoding {
+		rawSettings, err := c.rawSettings()
+		if err != nil {
+			return err
+		}
+
+		if err := c.collect(&errs, c.checkUnusedKeys(c.strictSettings(rawSettings, config), config)); err != nil {
+			return err
+		}
+	}
+
+	// Get all settings from Viper (from both env and the file) and apply global env settings
+	allSettings, err := c.mergedSettings()
+	if err != nil {
+		return err
+	}
+
+	// Decode settings into the provided config structure, a failure is
+	// reported with the keys holding the values that can't be decoded. The
+	// second pass is skipped since it would fail the same way
+	if err := c.decodeConfig(allSettings, config); err != nil {
+		return joinErrors(append(errs, c.newDecodeError(allSettings, config, err)))
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// Decode the settings again like Viper's Unmarshal does, matching the keys
+	// with the field names and with the custom DecodeHook
+	rawSettings, err := c.rawSettings()
+	if err != nil {
+		return err
+	}
+
+	if err := c.collect(&errs, c.decodeConfig(rawSettings, config, c.viperDecoderConfig(config))); err != nil {
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return joinErrors(errs)
+}
+
+// decodeConfig decodes the provided settings, usually a map, into the given config structure.
+func (c *Config) decodeConfig(settings interface{}, config interface{}, opts ...func(*mapstructure.DecoderConfig)) error {
 	decoderConfig := &mapstructure.DecoderConfig{
 		WeaklyTypedInput: true, // Allow flexible type matching
 		ZeroFields:       true, // Zero fields before decoding
 		Result:           config,
-		TagName:          "env", // Use `env` tags for field mapping
+		TagName:          c.tagName, // Use `env` tags for field mapping by default
+		DecodeHook:       c.typeDecodeHook(),
+		Squash:           true, // Flatten the embedded structs
+	}
+
+	for _, opt := range opts {
+		opt(decoderConfig)
 	}
 
 	decoder, err := mapstructure.NewDecoder(decoderConfig)
@@ -103,8 +1331,200 @@ func decodeConfig(settings map[string]interface{}, config interface{}) error {
 	return decoder.Decode(settings)
 }
 
+// viperDecoderConfig returns the decoder options of Viper's Unmarshal, which
+// doesn't zero the fields and, with the default tag name, matches the keys
+// with the field names. The custom tag name is used if one was set.
+func (c *Config) viperDecoderConfig(config interface{}) func(*mapstructure.DecoderConfig) {
+	return func(dc *mapstructure.DecoderConfig) {
+		dc.ZeroFields = false
+		dc.DecodeHook = mapstructure.ComposeDecodeHookFunc(c.mapstructureDecodeHook(config), c.typeDecodeHook())
+		if c.tagName == defaultTagName {
+			dc.TagName = ""
+		}
+	}
+}
+
+// checkUnusedKeys decodes the settings into a new value of the config type and
+// returns an error listing the keys that don't match any field. The unknown
+// keys of the root are reported with the name of the config type, e.g.
+// `'AppConfig' has invalid keys: timout`.
+func (c *Config) checkUnusedKeys(settings map[string]interface{}, config interface{}) error {
+	t := reflect.TypeOf(config)
+	if t == nil || t.Kind() != reflect.Ptr {
+		return nil
+	}
+
+	err := c.decodeConfig(settings, reflect.New(t.Elem()).Interface(), func(dc *mapstructure.DecoderConfig) {
+		dc.ErrorUnused = true
+	})
+
+	var decodeErr *mapstructure.Error
+	if errors.As(err, &decodeErr) {
+		for i, msg := range decodeErr.Errors {
+			if strings.HasPrefix(msg, "'' ") {
+				decodeErr.Errors[i] = fmt.Sprintf("'%s' %s", t.Elem().Name(), strings.TrimPrefix(msg, "'' "))
+			}
+		}
+	}
+
+	return err
+}
+
+// strictSettings returns the settings checked by the strict decoding of the
+// config structure. The keys only provided by the bound flags or Set are left
+// out, only the keys read from the config files are checked. For the files
+// shared by several structures, if there is a section named after the
+// structure only that section is checked, otherwise the sections named after
+// its nested structure types are left out, e.g. `serverconfig` for a field of
+// type ServerConfig.
+func (c *Config) strictSettings(settings map[string]interface{}, config interface{}) map[string]interface{} {
+	var keys []string
+	for _, set := range c.flagSets {
+		set.VisitAll(func(flag *pflag.Flag) {
+			keys = append(keys, strings.ToLower(flag.Name))
+		})
+	}
+	for key := range c.overrides {
+		keys = append(keys, key)
+	}
+
+	for _, key := range keys {
+		if !c.v.InConfig(key) {
+			deleteKey(settings, key)
+		}
+	}
+
+	t := reflect.TypeOf(config).Elem()
+	if section, ok := settings[strings.ToLower(t.Name())].(map[string]interface{}); ok {
+		return section
+	}
+
+	fields := make(map[string]bool)
+	for _, field := range c.structKeys("", t, nil) {
+		fields[strings.SplitN(field.key, ".", 2)[0]] = true
+	}
+
+	names := structTypeNames(t, nil)
+	checked := make(map[string]interface{}, len(settings))
+	for key, value := range settings {
+		if _, ok := value.(map[string]interface{}); ok && !fields[key] && names[key] {
+			continue
+		}
+		checked[key] = value
+	}
+
+	return checked
+}
+
+// structTypeNames returns the lowercased names of the struct types of the
+// fields of the struct type, walking the nested structures.
+func structTypeNames(t reflect.Type, names map[string]bool) map[string]bool {
+	if names == nil {
+		names = make(map[string]bool)
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		fieldType := t.Field(i).Type
+		for fieldType.Kind() == reflect.Ptr || fieldType.Kind() == reflect.Slice || fieldType.Kind() == reflect.Map {
+			fieldType = fieldType.Elem()
+		}
+
+		name := strings.ToLower(fieldType.Name())
+		if fieldType.Kind() != reflect.Struct || isValueType(fieldType) || names[name] {
+			continue
+		}
+
+		if name != "" {
+			names[name] = true
+		}
+		structTypeNames(fieldType, names)
+	}
+
+	return names
+}
+
+// checkConfig checks the config is a non-nil pointer to a struct, so it can be
+// decoded into.
+func checkConfig(config interface{}) error {
+	v := reflect.ValueOf(config)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("config must be a non-nil pointer to a struct, got %T", config)
+	}
+
+	return nil
+}
+
+// settings returns the merged settings, ignoring the environment variables
+// overriding the slice elements out of range.
+func (c *Config) settings() map[string]interface{} {
+	allSettings, _ := c.mergedSettings()
+
+	return allSettings
+}
+
+// mergedSettings returns all settings from Viper with the global env settings
+// applied, if the propagation is enabled.
+func (c *Config) mergedSettings() (map[string]interface{}, error) {
+	allSettings, err := c.rawSettings()
+	if c.globalEnvPropagation {
+		allSettings = applyGlobalEnvSettings(allSettings)
+	}
+
+	return allSettings, err
+}
+
+// rawSettings returns all settings from Viper with the slice elements
+// overridden by the environment variables of their index, and with the
+// `_FILE` environment variables read if enabled.
+func (c *Config) rawSettings() (map[string]interface{}, error) {
+	allSettings := c.v.AllSettings()
+	c.applyFlags(allSettings)
+	if c.keyNamingStrategy != nil {
+		allSettings = normalizeKeys(allSettings, c.keyNamingStrategy, c.keyCases, "")
+	}
+
+	if c.envExpansion {
+		if err := c.expandEnv("", allSettings); err != nil {
+			return allSettings, err
+		}
+	}
+
+	if c.emptyAsUnset {
+		dropEmptyStrings(allSettings)
+	}
+
+	if c.fileEnv {
+		if err := c.applyFileEnv(allSettings); err != nil {
+			return allSettings, err
+		}
+	}
+
+	return allSettings, c.applySliceEnv("", allSettings)
+}
+
+// dropEmptyStrings deletes the keys holding an empty string from the settings
+// and their nested sections.
+func dropEmptyStrings(settings map[string]interface{}) {
+	for key, value := range settings {
+		switch value := value.(type) {
+		case string:
+			if value == "" {
+				delete(settings, key)
+			}
+		case map[string]interface{}:
+			dropEmptyStrings(value)
+		}
+	}
+}
+
 // applyGlobalEnvSettings applies global environment variables to all settings.
+// The settings are nil or empty when the config file is empty, then there is
+// nothing to apply.
 func applyGlobalEnvSettings(allSettings map[string]interface{}) map[string]interface{} {
+	if len(allSettings) == 0 {
+		return allSettings
+	}
+
 	// Get all global environment variables
 	globalEnvs := make(map[string]interface{})
 	for k, v := range allSettings {
@@ -116,10 +1536,16 @@ func applyGlobalEnvSettings(allSettings map[string]interface{}) map[string]inter
 		}
 	}
 
-	// Apply global environment variables to all settings
+	// Apply global environment variables to all settings, except to the
+	// sections holding only nested sections, e.g. a map of named backends
 	for _, v := range allSettings {
 		switch v := v.(type) {
 		case map[string]interface{}:
+			// A nil section, e.g. from a settings map, can't be written
+			if v == nil || isSectionMap(v) {
+				continue
+			}
+
 			for gKey, gVal := range globalEnvs {
 				if _, ok := v[gKey]; !ok {
 					v[gKey] = gVal
@@ -131,18 +1557,59 @@ func applyGlobalEnvSettings(allSettings map[string]interface{}) map[string]inter
 	return allSettings
 }
 
+// isSectionMap reports whether all the values of the map are maps.
+func isSectionMap(m map[string]interface{}) bool {
+	if len(m) == 0 {
+		return false
+	}
+
+	for _, v := range m {
+		if _, ok := v.(map[string]interface{}); !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
 // mapstructureDecodeHook handles custom decoding logic for environment variables
-func mapstructureDecodeHook(config interface{}) mapstructure.DecodeHookFunc {
+func (c *Config) mapstructureDecodeHook(config interface{}) mapstructure.DecodeHookFunc {
+	// The first call is the one decoding the settings into the config structure
+	root := true
+
 	return func(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
-		// If it's a map, try to match it with the structure name
+		if !root {
+			return data, nil
+		}
+		root = false
+
+		// If it's a map decoded into the config structure, try to match it with
+		// the structure name. The nested structures, maps and slices, even of the
+		// same type, are left to mapstructure's default handling
 		if f.Kind() == reflect.Map && data != nil {
-			v, ok := data.(map[string]interface{})[strings.ToLower(t.Name())]
+			settings, ok := data.(map[string]interface{})
+			if !ok {
+				return data, nil
+			}
+
+			name := strings.ToLower(t.Name())
+			v, ok := settings[name].(map[string]interface{})
 			if !ok {
 				return data, nil
 			}
 
+			// The section takes the place of the config file values, so the
+			// keys set by the flags, Set and the environment variables still
+			// take precedence over it
+			v = copySettings(v)
+			for key, value := range flattenSettings("", settings, make(map[string]interface{})) {
+				if !strings.HasPrefix(key, name+".") && c.overridden(key) {
+					setKey(v, key, value)
+				}
+			}
+
 			// Decode the map into the structure using mapstructure
-			if err := decodeConfig(v.(map[string]interface{}), config); err != nil {
+			if err := c.decodeConfig(v, config); err != nil {
 				return nil, err
 			}
 
@@ -153,17 +1620,82 @@ func mapstructureDecodeHook(config interface{}) mapstructure.DecodeHookFunc {
 	}
 }
 
-// validateConfig validates the provided config structure using go-playground/validator
-func validateConfig(config interface{}) error {
-	validate := validator.New()
-	if err := validate.Struct(config); err != nil {
-		var errorMessages []string
-		for _, err := range err.(validator.ValidationErrors) {
-			errorMessages = append(errorMessages, fmt.Sprintf("validation error: field '%s' is %s", err.Field(), err.Tag()))
-		}
+// RegisterValidation registers a custom validation function for the given tag,
+// so it can be used in the `validate` tags of the config structures.
+func (c *Config) RegisterValidation(tag string, fn validator.Func) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.validate.RegisterValidation(tag, fn)
+}
+
+// RegisterStructValidation registers a validation function for the structures
+// of the given types, for the rules spanning several fields, e.g. a start date
+// before the end date. The failures are reported with StructLevel.ReportError.
+func (c *Config) RegisterStructValidation(fn validator.StructLevelFunc, types ...interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.validate.RegisterStructValidation(fn, types...)
+}
+
+// Validate validates the config structure with the same rules Unmarshal uses,
+// including the custom validations, e.g. for structures built by hand.
+func (c *Config) Validate(config interface{}) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.validateConfig(config)
+}
+
+// keyName returns the name of the key the field is decoded from, the name in
+// the tag or the field name, or an empty string if the field is skipped.
+func (c *Config) keyName(field reflect.StructField) string {
+	return tagKeyName(field, c.tagName)
+}
 
-		return fmt.Errorf("errors: %s", strings.Join(errorMessages, ", "))
+// tagKeyName returns the name of the key the field is decoded from with the
+// tag, or an empty string if the field is skipped.
+func tagKeyName(field reflect.StructField, tag string) string {
+	name := strings.SplitN(field.Tag.Get(tag), ",", 2)[0]
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return field.Name
 	}
 
-	return nil
+	return name
+}
+
+// fieldName returns the name of the field in the validationTagName tag, or the
+// lowercased field name if the tag is not set, since the keys are matched with
+// the field names case-insensitively.
+func (c *Config) fieldName(field reflect.StructField) string {
+	name := strings.SplitN(field.Tag.Get(c.validationTagName), ",", 2)[0]
+	if name == "" || name == "-" {
+		return strings.ToLower(field.Name)
+	}
+
+	return name
+}
+
+// Defaults sets only the values of the `default` tags on the config structure,
+// e.g. to document the default configuration.
+func Defaults(config interface{}) error {
+	if err := checkConfig(config); err != nil {
+		return err
+	}
+
+	return defaults.Set(config)
+}
+
+// validateConfig validates the provided config structure using go-playground/validator
+func (c *Config) validateConfig(config interface{}) error {
+	err := c.validate.Struct(config)
+	if errs, ok := err.(validator.ValidationErrors); ok {
+		return newValidationError(errs, c.errorFormatter)
+	}
+
+	return err
 }
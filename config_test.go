@@ -0,0 +1,1629 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// newYAML creates a Config reading the YAML document, failing the test if it
+// can't be read.
+func newYAML(t *testing.T, yaml string, opts ...Option) *Config {
+	t.Helper()
+
+	c := New(append([]Option{WithReader(strings.NewReader(yaml), "yaml")}, opts...)...)
+	if err := c.Err(); err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	return c
+}
+
+func TestPrecedence(t *testing.T) {
+	type config struct {
+		Host string `env:"host" default:"tag"`
+	}
+
+	// The sources of the host, from the highest precedence to the lowest
+	sources := []string{"flag", "set", "env", "file", "defaults file", "default tag"}
+
+	for i, high := range sources {
+		for _, low := range sources[i+1:] {
+			t.Run(high+" over "+low, func(t *testing.T) {
+				c := newPrecedenceConfig(t, "host", high, low)
+
+				var cfg config
+				if err := c.Unmarshal(&cfg); err != nil {
+					t.Fatalf("Unmarshal() error = %v", err)
+				}
+
+				if cfg.Host != high {
+					t.Errorf("Host = %q, want %q", cfg.Host, high)
+				}
+				if got := c.GetString("host"); high != "default tag" && got != high {
+					t.Errorf("GetString() = %q, want %q", got, high)
+				}
+			})
+		}
+	}
+}
+
+func TestPrecedenceStructureSection(t *testing.T) {
+	type ServerConfig struct {
+		Host string
+	}
+
+	// The section named after the structure has the precedence of the file
+	for _, high := range []string{"flag", "set", "env"} {
+		t.Run(high+" over section", func(t *testing.T) {
+			c := newPrecedenceConfig(t, "serverconfig.host", high, "file")
+
+			var cfg ServerConfig
+			if err := c.Unmarshal(&cfg); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+
+			if cfg.Host != high {
+				t.Errorf("Host = %q, want %q", cfg.Host, high)
+			}
+		})
+	}
+
+	t.Run("section over file", func(t *testing.T) {
+		c := newYAML(t, "host: top\nserverconfig:\n  host: section")
+
+		var cfg ServerConfig
+		if err := c.Unmarshal(&cfg); err != nil {
+			t.Fatalf("Unmarshal() error = %v", err)
+		}
+
+		if cfg.Host != "section" {
+			t.Errorf("Host = %q, want %q", cfg.Host, "section")
+		}
+	})
+}
+
+// newPrecedenceConfig creates a Config setting the host in the sources, to a
+// value named after the source. The file sets the key, the other sources set
+// the top-level host.
+func newPrecedenceConfig(t *testing.T, fileKey string, sources ...string) *Config {
+	t.Helper()
+
+	has := func(source string) bool {
+		for _, s := range sources {
+			if s == source {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	yaml := ""
+	if has("file") {
+		parts := strings.Split(fileKey, ".")
+		for i, part := range parts {
+			yaml += strings.Repeat("  ", i) + part + ":"
+			if i < len(parts)-1 {
+				yaml += "\n"
+			}
+		}
+		yaml += " file"
+	}
+
+	opts := []Option{WithEnvPrefix("PRECEDENCE")}
+	if has("env") {
+		t.Setenv("PRECEDENCE_HOST", "env")
+	}
+	if has("defaults file") {
+		path := filepath.Join(t.TempDir(), "defaults.yaml")
+		if err := os.WriteFile(path, []byte("host: defaults file"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		opts = append(opts, WithDefaultsFile(path))
+	}
+
+	c := newYAML(t, yaml, opts...)
+	if has("set") {
+		if err := c.Set("host", "set"); err != nil {
+			t.Fatalf("Set() error = %v", err)
+		}
+	}
+	if has("flag") {
+		flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+		flags.String("host", "", "")
+		if err := flags.Parse([]string{"--host=flag"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.BindPFlags(flags); err != nil {
+			t.Fatalf("BindPFlags() error = %v", err)
+		}
+	}
+
+	return c
+}
+
+func TestGlobalEnvPropagation(t *testing.T) {
+	type config struct {
+		Name     string `env:"name"`
+		Database struct {
+			Name string `env:"name"`
+			Host string `env:"host"`
+		} `env:"database"`
+	}
+
+	yaml := "name: app\ndatabase:\n  host: db"
+
+	tests := []struct {
+		name string
+		opts []Option
+		want string
+	}{
+		{name: "disabled by default", want: ""},
+		{name: "disabled", opts: []Option{WithGlobalEnvPropagation(false)}, want: ""},
+		{name: "enabled", opts: []Option{WithGlobalEnvPropagation(true)}, want: "app"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newYAML(t, yaml, tt.opts...)
+
+			var cfg config
+			if err := c.Unmarshal(&cfg); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+
+			if cfg.Database.Name != tt.want {
+				t.Errorf("Database.Name = %q, want %q", cfg.Database.Name, tt.want)
+			}
+			if cfg.Name != "app" || cfg.Database.Host != "db" {
+				t.Errorf("Name = %q, Database.Host = %q, want %q and %q", cfg.Name, cfg.Database.Host, "app", "db")
+			}
+			if got := c.Get("database.name"); (got != nil) != (tt.want != "") {
+				t.Errorf("Get() = %v, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConcurrentUse(t *testing.T) {
+	type config struct {
+		Port     int `env:"port"`
+		Database struct {
+			Host string `env:"host"`
+		} `env:"database"`
+	}
+
+	c := newYAML(t, "port: 8080\ndatabase:\n  host: db")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(3)
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 50; j++ {
+				var cfg config
+				if err := c.Unmarshal(&cfg); err != nil {
+					t.Errorf("Unmarshal() error = %v", err)
+					return
+				}
+			}
+		}()
+
+		go func(i int) {
+			defer wg.Done()
+
+			for j := 0; j < 50; j++ {
+				if err := c.Set("port", 9000+i); err != nil {
+					t.Errorf("Set() error = %v", err)
+					return
+				}
+			}
+		}(i)
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 50; j++ {
+				c.GetString("database.host")
+				c.Settings()
+				c.IsSet("port")
+			}
+		}()
+	}
+	wg.Wait()
+
+	var cfg config
+	if err := c.Unmarshal(&cfg); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if cfg.Port < 9000 || cfg.Database.Host != "db" {
+		t.Errorf("config = %+v, want a port set with Set and host %q", cfg, "db")
+	}
+}
+
+func TestStrictDecoding(t *testing.T) {
+	type DatabaseConfig struct {
+		Host string `env:"host"`
+	}
+
+	type AppConfig struct {
+		Timeout  int            `env:"timeout"`
+		Database DatabaseConfig `env:"database"`
+	}
+
+	tests := []struct {
+		name    string
+		yaml    string
+		opts    []Option
+		decode  func(c *Config) error
+		wantErr string
+	}{
+		{
+			name:   "known keys",
+			yaml:   "timeout: 5\ndatabase:\n  host: db",
+			opts:   []Option{WithStrictDecoding()},
+			decode: func(c *Config) error { return c.Unmarshal(&AppConfig{}) },
+		},
+		{
+			name:    "unknown key",
+			yaml:    "timout: 5",
+			opts:    []Option{WithStrictDecoding()},
+			decode:  func(c *Config) error { return c.Unmarshal(&AppConfig{}) },
+			wantErr: "'AppConfig' has invalid keys: timout",
+		},
+		{
+			name: "unrelated flag",
+			yaml: "timeout: 5",
+			opts: []Option{WithStrictDecoding()},
+			decode: func(c *Config) error {
+				set := pflag.NewFlagSet("test", pflag.ContinueOnError)
+				set.Int("timeout", 0, "")
+				set.Bool("verbose", false, "")
+				if err := set.Parse([]string{"--timeout", "10", "--verbose"}); err != nil {
+					return err
+				}
+				if err := c.BindPFlags(set); err != nil {
+					return err
+				}
+
+				return c.Unmarshal(&AppConfig{})
+			},
+		},
+		{
+			name: "unrelated Set key",
+			yaml: "timeout: 5",
+			opts: []Option{WithStrictDecoding()},
+			decode: func(c *Config) error {
+				c.Set("verbose", true)
+
+				return c.Unmarshal(&AppConfig{})
+			},
+		},
+		{
+			name: "unknown key in the file also set by a flag",
+			yaml: "verbose: true",
+			opts: []Option{WithStrictDecoding()},
+			decode: func(c *Config) error {
+				set := pflag.NewFlagSet("test", pflag.ContinueOnError)
+				set.Bool("verbose", false, "")
+				if err := c.BindPFlags(set); err != nil {
+					return err
+				}
+
+				return c.Unmarshal(&AppConfig{})
+			},
+			wantErr: "verbose",
+		},
+		{
+			name:    "unknown nested key",
+			yaml:    "database:\n  hots: db",
+			opts:    []Option{WithStrictDecoding()},
+			decode:  func(c *Config) error { return c.Unmarshal(&AppConfig{}) },
+			wantErr: "hots",
+		},
+		{
+			name:   "unknown key without strict mode",
+			yaml:   "timout: 5",
+			decode: func(c *Config) error { return c.Unmarshal(&AppConfig{}) },
+		},
+		{
+			name:   "shared file, root structure",
+			yaml:   "timeout: 5\ndatabaseconfig:\n  host: db",
+			opts:   []Option{WithStrictDecoding()},
+			decode: func(c *Config) error { return c.Unmarshal(&AppConfig{}) },
+		},
+		{
+			name:   "shared file, structure section",
+			yaml:   "timeout: 5\ndatabaseconfig:\n  host: db",
+			opts:   []Option{WithStrictDecoding()},
+			decode: func(c *Config) error { return c.Unmarshal(&DatabaseConfig{}) },
+		},
+		{
+			name:    "shared file, unknown key in the structure section",
+			yaml:    "timeout: 5\ndatabaseconfig:\n  hots: db",
+			opts:    []Option{WithStrictDecoding()},
+			decode:  func(c *Config) error { return c.Unmarshal(&DatabaseConfig{}) },
+			wantErr: "hots",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.decode(newYAML(t, tt.yaml, tt.opts...))
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("Unmarshal() error = %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Fatalf("Unmarshal() error = %v, want an error listing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClone(t *testing.T) {
+	t.Setenv("HOST", "env")
+
+	c := newYAML(t, "host: file\nport: 8080")
+	c.Set("host", "set")
+
+	clone := c.Clone()
+	if err := clone.Err(); err != nil {
+		t.Fatalf("Clone().Err() = %v", err)
+	}
+
+	// The values set by Set keep their precedence over the environment
+	if got := clone.GetString("host"); got != "set" {
+		t.Errorf("GetString(host) = %q, want %q", got, "set")
+	}
+
+	var cfg struct {
+		Host string `env:"host"`
+	}
+	if err := clone.Unmarshal(&cfg); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if cfg.Host != "set" {
+		t.Errorf("Host = %q, want %q", cfg.Host, "set")
+	}
+
+	clone.Set("port", 9090)
+	if got := c.GetInt("port"); got != 8080 {
+		t.Errorf("original GetInt(port) = %d, want 8080", got)
+	}
+}
+
+func TestCloneError(t *testing.T) {
+	errBind := errors.New("bind failed")
+
+	c := newYAML(t, "port: 8080")
+	c.bindings = append(c.bindings, func(*viper.Viper) error {
+		return errBind
+	})
+
+	if err := c.Clone().Err(); !errors.Is(err, errBind) {
+		t.Errorf("Clone().Err() = %v, want %v", err, errBind)
+	}
+}
+
+func TestResetEnv(t *testing.T) {
+	type config struct {
+		Host string `env:"host"`
+		Port int    `env:"port"`
+	}
+
+	t.Setenv("PORT", "8080")
+
+	c := newYAML(t, "host: localhost")
+	c.Set("host", "example.com")
+
+	var cfg config
+	if err := c.Unmarshal(&cfg); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if cfg.Port != 8080 || cfg.Host != "example.com" {
+		t.Fatalf("config = %+v, want port 8080 and host example.com", cfg)
+	}
+
+	// The new environment is read and the values set by Set are forgotten
+	t.Setenv("PORT", "9090")
+	if err := c.Reset(); err != nil {
+		t.Fatalf("Reset() error = %v", err)
+	}
+
+	cfg = config{}
+	if err := c.Unmarshal(&cfg); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if cfg.Port != 9090 || cfg.Host != "localhost" {
+		t.Errorf("config = %+v, want port 9090 and host localhost", cfg)
+	}
+}
+
+func TestErr(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{name: "valid file", content: "port: 8080"},
+		{name: "malformed file", content: "port: [8080", wantErr: true},
+		{name: "missing optional file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tt.content != "" {
+				writeFile(t, filepath.Join(dir, "config.yaml"), tt.content)
+			}
+
+			err := New(WithFilePath(dir), WithFileName("config")).Err()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Err() = %v, want error %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFilePaths(t *testing.T) {
+	first, second := t.TempDir(), t.TempDir()
+	writeFile(t, filepath.Join(second, "config.yaml"), "port: 9090")
+
+	c := New(WithFilePaths(first, second), WithFileName("config"))
+	if err := c.Err(); err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	if got := c.GetInt("port"); got != 9090 {
+		t.Errorf("GetInt() = %d, want 9090 from the second path", got)
+	}
+
+	// The file of the first path wins once it exists
+	writeFile(t, filepath.Join(first, "config.yaml"), "port: 8080")
+	if err := c.Reset(); err != nil {
+		t.Fatalf("Reset() error = %v", err)
+	}
+	if got := c.GetInt("port"); got != 8080 {
+		t.Errorf("GetInt() = %d, want 8080 from the first path", got)
+	}
+}
+
+func TestTagName(t *testing.T) {
+	type config struct {
+		MaxConns int `mapstructure:"max_conns"`
+		Database struct {
+			Host string `mapstructure:"hostname"`
+		} `mapstructure:"db"`
+	}
+
+	t.Setenv("DB_HOSTNAME", "env")
+
+	c := newYAML(t, "max_conns: 10\ndb:\n  hostname: file", WithTagName("mapstructure"))
+
+	var cfg config
+	if err := c.Unmarshal(&cfg); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	if cfg.MaxConns != 10 || cfg.Database.Host != "env" {
+		t.Errorf("config = %+v, want max_conns 10 and db.hostname from DB_HOSTNAME", cfg)
+	}
+}
+
+func TestDefaultsFirst(t *testing.T) {
+	type config struct {
+		Port int    `env:"port" default:"8080"`
+		Host string `env:"host" default:"localhost"`
+	}
+
+	tests := []struct {
+		name string
+		opts []Option
+		want config
+	}{
+		{name: "defaults last", want: config{Port: 8080, Host: "localhost"}},
+		{name: "defaults first", opts: []Option{WithDefaultsFirst()}, want: config{Port: 0, Host: "localhost"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg config
+			if err := newYAML(t, "port: 0", tt.opts...).Unmarshal(&cfg); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+
+			if cfg != tt.want {
+				t.Errorf("config = %+v, want %+v", cfg, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileType(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "config.toml"), "port = 8080\n\n[database]\nhost = \"db\"\n")
+
+	c := New(WithFilePath(dir), WithFileName("config"), WithFileType("toml"))
+	if err := c.Err(); err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if got := c.GetInt("port"); got != 8080 {
+		t.Errorf("GetInt() = %d, want 8080", got)
+	}
+	if got := c.GetString("database.host"); got != "db" {
+		t.Errorf("GetString() = %q, want %q", got, "db")
+	}
+
+	var unsupported viper.UnsupportedConfigError
+	if err := New(WithFilePath(dir), WithFileName("config"), WithFileType("xml")).Err(); !errors.As(err, &unsupported) {
+		t.Errorf("Err() = %v, want a viper.UnsupportedConfigError", err)
+	}
+}
+
+func TestMergeFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "config.yaml"), "database:\n  host: localhost\n  port: 5432")
+	writeFile(t, filepath.Join(dir, "config.production.yaml"), "database:\n  host: db.example.com")
+
+	c := New(WithFilePath(dir), WithMergeFiles("config", "config.production"))
+	if err := c.Err(); err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	var cfg struct {
+		Database struct {
+			Host string `env:"host"`
+			Port int    `env:"port"`
+		} `env:"database"`
+	}
+	if err := c.Unmarshal(&cfg); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	if cfg.Database.Host != "db.example.com" || cfg.Database.Port != 5432 {
+		t.Errorf("Database = %+v, want the host of the later file and the port of the base one", cfg.Database)
+	}
+}
+
+func TestUnmarshalContext(t *testing.T) {
+	type config struct {
+		Port int `env:"port" default:"8080"`
+	}
+
+	c := newYAML(t, "port: 9090")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var cfg config
+	if err := c.UnmarshalContext(ctx, &cfg); !errors.Is(err, context.Canceled) {
+		t.Errorf("UnmarshalContext() error = %v, want %v", err, context.Canceled)
+	}
+
+	if err := c.UnmarshalContext(context.Background(), &cfg); err != nil || cfg.Port != 9090 {
+		t.Errorf("UnmarshalContext() = %+v, %v, want port 9090", cfg, err)
+	}
+}
+
+func TestEmbeddedStruct(t *testing.T) {
+	type Base struct {
+		Name     string `env:"name"`
+		LogLevel string `env:"log_level"`
+	}
+	type Database struct {
+		Host string `env:"host"`
+	}
+	type App struct {
+		Base
+		Port     int      `env:"port"`
+		Database Database `env:"database"`
+	}
+
+	t.Setenv("LOG_LEVEL", "debug")
+
+	var cfg App
+	if err := newYAML(t, "name: app\nport: 8080\ndatabase:\n  host: db").Unmarshal(&cfg); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	want := App{Base: Base{Name: "app", LogLevel: "debug"}, Port: 8080, Database: Database{Host: "db"}}
+	if cfg != want {
+		t.Errorf("config = %+v, want %+v", cfg, want)
+	}
+}
+
+func TestMixedCaseKeys(t *testing.T) {
+	type config struct {
+		MaxConns  int    `env:"MaxConns"`
+		LogLevel  string `env:"loglevel"`
+		ServerURL string
+		Labels    map[string]string `env:"Labels"`
+	}
+
+	yaml := "MaxConns: 10\nLogLevel: debug\nserverUrl: http://localhost\nLabels:\n  Team: core"
+
+	var cfg config
+	if err := newYAML(t, yaml).Unmarshal(&cfg); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	if cfg.MaxConns != 10 || cfg.LogLevel != "debug" || cfg.ServerURL != "http://localhost" {
+		t.Errorf("config = %+v, want the mixed-case keys matched ignoring the case", cfg)
+	}
+
+	// The case of the map keys is not preserved
+	if cfg.Labels["team"] != "core" {
+		t.Errorf("Labels = %v, want the lowercased key team", cfg.Labels)
+	}
+}
+
+func TestUnmarshalTarget(t *testing.T) {
+	type SubConfig struct {
+		Host string `env:"host"`
+	}
+	type config struct {
+		Sub *SubConfig `env:"sub"`
+	}
+
+	c := newYAML(t, "sub:\n  host: db")
+
+	var nilConfig *config
+	if err := c.Unmarshal(nilConfig); err == nil || !strings.Contains(err.Error(), "non-nil pointer") {
+		t.Errorf("Unmarshal(nil) error = %v, want the non-nil pointer error", err)
+	}
+	if err := c.Unmarshal(config{}); err == nil || !strings.Contains(err.Error(), "non-nil pointer") {
+		t.Errorf("Unmarshal(struct) error = %v, want the non-nil pointer error", err)
+	}
+
+	var cfg config
+	if err := c.Unmarshal(&cfg); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if cfg.Sub == nil || cfg.Sub.Host != "db" {
+		t.Errorf("Sub = %+v, want an allocated SubConfig with host db", cfg.Sub)
+	}
+}
+
+func TestMapOfStructs(t *testing.T) {
+	type Backend struct {
+		URL     string `env:"url"`
+		Weight  int    `env:"weight"`
+		Enabled bool   `env:"enabled"`
+	}
+	type config struct {
+		Backends map[string]Backend `env:"backends"`
+	}
+
+	yaml := "backends:\n  primary:\n    url: http://a\n    weight: 3\n    enabled: true\n  secondary:\n    url: http://b\n    weight: 1\n    enabled: false"
+
+	var cfg config
+	if err := newYAML(t, yaml).Unmarshal(&cfg); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	want := map[string]Backend{
+		"primary":   {URL: "http://a", Weight: 3, Enabled: true},
+		"secondary": {URL: "http://b", Weight: 1},
+	}
+	if !reflect.DeepEqual(cfg.Backends, want) {
+		t.Errorf("Backends = %+v, want %+v", cfg.Backends, want)
+	}
+}
+
+func TestWithoutDefaults(t *testing.T) {
+	type config struct {
+		Name string `env:"name" default:"x"`
+		Port int    `env:"port" default:"8080"`
+	}
+
+	tests := []struct {
+		name string
+		opts []Option
+		want config
+	}{
+		{name: "defaults", want: config{Name: "x", Port: 9090}},
+		{name: "without defaults", opts: []Option{WithoutDefaults()}, want: config{Port: 9090}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg config
+			if err := newYAML(t, "port: 9090", tt.opts...).Unmarshal(&cfg); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+
+			if cfg != tt.want {
+				t.Errorf("config = %+v, want %+v", cfg, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnvironmentFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "config.yaml"), "host: localhost\nport: 8080")
+	writeFile(t, filepath.Join(dir, "config.staging.yaml"), "host: staging.example.com")
+
+	tests := []struct {
+		name     string
+		env      string
+		wantHost string
+	}{
+		{name: "staging", env: "staging", wantHost: "staging.example.com"},
+		{name: "unset", wantHost: "localhost"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("APP_ENV", tt.env)
+
+			c := New(WithFilePath(dir), WithFileName("config"), WithEnvironmentFile("APP_ENV"))
+			if err := c.Err(); err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			if got := c.GetString("host"); got != tt.wantHost {
+				t.Errorf("GetString(host) = %q, want %q", got, tt.wantHost)
+			}
+			if got := c.GetInt("port"); got != 8080 {
+				t.Errorf("GetInt(port) = %d, want 8080 from the base file", got)
+			}
+		})
+	}
+}
+
+func TestRequireFile(t *testing.T) {
+	dir := t.TempDir()
+
+	// Optional by default, the configuration can come from the environment
+	if err := New(WithFilePath(dir), WithFileName("config")).Err(); err != nil {
+		t.Errorf("Err() = %v, want nil for a missing optional file", err)
+	}
+
+	var notFound viper.ConfigFileNotFoundError
+	if err := New(WithFilePath(dir), WithFileName("config"), WithRequireFile()).Err(); !errors.As(err, &notFound) {
+		t.Errorf("Err() = %v, want a viper.ConfigFileNotFoundError", err)
+	}
+
+	writeFile(t, filepath.Join(dir, "config.yaml"), "port: 8080")
+	if err := New(WithFilePath(dir), WithFileName("config"), WithRequireFile()).Err(); err != nil {
+		t.Errorf("Err() = %v, want nil for an existing required file", err)
+	}
+}
+
+func TestFS(t *testing.T) {
+	type config struct {
+		Port     int `env:"port"`
+		Database struct {
+			Host string `env:"host"`
+		} `env:"database"`
+	}
+
+	fsys := fstest.MapFS{
+		"defaults/config.yaml": {Data: []byte("port: 8080\ndatabase:\n  host: db")},
+	}
+
+	t.Setenv("PORT", "9090")
+
+	c := New(WithFS(fsys, "defaults/config.yaml"))
+	if err := c.Err(); err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	var cfg config
+	if err := c.Unmarshal(&cfg); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	// The embedded defaults are overridden by the environment
+	if cfg.Port != 9090 || cfg.Database.Host != "db" {
+		t.Errorf("config = %+v, want port 9090 and database.host db", cfg)
+	}
+
+	if err := New(WithFS(fsys, "missing.yaml")).Err(); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Err() = %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestConfigFileUsed(t *testing.T) {
+	first, second := t.TempDir(), t.TempDir()
+	path := filepath.Join(second, "config.yaml")
+	writeFile(t, path, "port: 8080")
+
+	if got := New(WithFilePaths(first, second), WithFileName("config")).ConfigFileUsed(); got != path {
+		t.Errorf("ConfigFileUsed() = %q, want %q", got, path)
+	}
+
+	if got := New(WithFilePath(first), WithFileName("config")).ConfigFileUsed(); got != "" {
+		t.Errorf("ConfigFileUsed() = %q, want an empty string without file", got)
+	}
+}
+
+func TestSliceOfStructsAndFloats(t *testing.T) {
+	type Server struct {
+		Host string `env:"host"`
+		Port int    `env:"port"`
+	}
+	type config struct {
+		Servers []Server `env:"servers"`
+		Ratio   float64  `env:"ratio"`
+		Scale   float64  `env:"scale"`
+	}
+
+	yaml := "servers:\n  - host: a\n    port: 1\n  - host: b\n    port: 2\nratio: 0.75\nscale: 2"
+
+	var cfg config
+	if err := newYAML(t, yaml).Unmarshal(&cfg); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	want := config{Servers: []Server{{Host: "a", Port: 1}, {Host: "b", Port: 2}}, Ratio: 0.75, Scale: 2}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("config = %+v, want %+v", cfg, want)
+	}
+}
+
+func TestMustUnmarshal(t *testing.T) {
+	type config struct {
+		Host string `env:"host" validate:"required"`
+	}
+
+	var cfg config
+	newYAML(t, "host: localhost").MustUnmarshal(&cfg)
+	if cfg.Host != "localhost" {
+		t.Errorf("Host = %q, want %q", cfg.Host, "localhost")
+	}
+
+	defer func() {
+		err, ok := recover().(error)
+		if !ok || !strings.Contains(err.Error(), "field 'host' is required") {
+			t.Errorf("recover() = %v, want the validation error", err)
+		}
+	}()
+
+	newYAML(t, "port: 8080").MustUnmarshal(&config{})
+	t.Error("MustUnmarshal() didn't panic")
+}
+
+func TestRegisterAlias(t *testing.T) {
+	type config struct {
+		Port int `env:"port"`
+	}
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		set  bool
+		want int
+	}{
+		{name: "alias only", env: map[string]string{"LEGACY_PORT": "9090"}, want: 9090},
+		{name: "both set", env: map[string]string{"LEGACY_PORT": "9090", "PORT": "7070"}, want: 7070},
+		{name: "set through the alias", set: true, want: 8080},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			c := newYAML(t, "host: localhost")
+			if err := c.RegisterAlias("legacy_port", "port"); err != nil {
+				t.Fatalf("RegisterAlias() error = %v", err)
+			}
+
+			if tt.set {
+				c.Set("legacy_port", 8080)
+			}
+
+			var cfg config
+			if err := c.Unmarshal(&cfg); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+
+			if cfg.Port != tt.want {
+				t.Errorf("Port = %d, want %d", cfg.Port, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteConfigAs(t *testing.T) {
+	type config struct {
+		Host     string `env:"host"`
+		Port     int    `env:"port"`
+		Database struct {
+			Hosts []string `env:"hosts"`
+		} `env:"database"`
+	}
+
+	c := newYAML(t, "host: localhost\nport: 8080\ndatabase:\n  hosts:\n    - a\n    - b")
+	c.Set("port", 9090)
+
+	path := filepath.Join(t.TempDir(), "effective.yaml")
+	if err := c.WriteConfigAs(path); err != nil {
+		t.Fatalf("WriteConfigAs() error = %v", err)
+	}
+
+	written := New(WithConfigFile(path))
+	if err := written.Err(); err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	var want, got config
+	if err := c.Unmarshal(&want); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if err := written.Unmarshal(&got); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) || got.Port != 9090 {
+		t.Errorf("written config = %+v, want %+v", got, want)
+	}
+}
+
+func TestDotEnv(t *testing.T) {
+	type config struct {
+		Port     int    `env:"port"`
+		LogLevel string `env:"log_level"`
+		Debug    bool   `env:"debug"`
+	}
+
+	path := filepath.Join(t.TempDir(), ".env")
+	writeFile(t, path, "# local settings\nPORT=8080\nLOG_LEVEL=\"debug\"\n\nDEBUG=true\n")
+
+	c := New(WithDotEnv(path))
+	if err := c.Err(); err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	var cfg config
+	if err := c.Unmarshal(&cfg); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	want := config{Port: 8080, LogLevel: "debug", Debug: true}
+	if cfg != want {
+		t.Errorf("config = %+v, want %+v", cfg, want)
+	}
+}
+
+func TestDecodeOnly(t *testing.T) {
+	type config struct {
+		Host string `env:"host" default:"localhost" validate:"required"`
+		Port int    `env:"port" default:"8080"`
+	}
+
+	var cfg config
+	if err := newYAML(t, "port: 9090").DecodeOnly(&cfg); err != nil {
+		t.Fatalf("DecodeOnly() error = %v", err)
+	}
+
+	// Neither the defaults are applied nor the required host validated
+	if want := (config{Port: 9090}); cfg != want {
+		t.Errorf("config = %+v, want %+v", cfg, want)
+	}
+}
+
+func TestDefaults(t *testing.T) {
+	type Database struct {
+		Host string `env:"host" default:"localhost"`
+		Port int    `env:"port" default:"5432"`
+	}
+	type config struct {
+		Name     string        `env:"name" default:"app"`
+		Timeout  time.Duration `env:"timeout" default:"30s"`
+		Hosts    []string      `env:"hosts" default:"[\"a\",\"b\"]"`
+		Database Database      `env:"database"`
+	}
+
+	var cfg config
+	if err := Defaults(&cfg); err != nil {
+		t.Fatalf("Defaults() error = %v", err)
+	}
+
+	want := config{
+		Name:     "app",
+		Timeout:  30 * time.Second,
+		Hosts:    []string{"a", "b"},
+		Database: Database{Host: "localhost", Port: 5432},
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("config = %+v, want %+v", cfg, want)
+	}
+
+	if err := Defaults(config{}); err == nil {
+		t.Error("Defaults() error = nil, want the non-nil pointer error")
+	}
+}
+
+func TestSub(t *testing.T) {
+	type DBConfig struct {
+		Host string `env:"host" validate:"required"`
+		Port int    `env:"port" default:"5432"`
+	}
+
+	c := newYAML(t, "port: 8080\ndatabase:\n  host: db")
+
+	sub := c.Sub("database")
+	if sub == nil {
+		t.Fatal("Sub() = nil, want the database section")
+	}
+
+	var cfg DBConfig
+	if err := sub.Unmarshal(&cfg); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if want := (DBConfig{Host: "db", Port: 5432}); cfg != want {
+		t.Errorf("config = %+v, want %+v", cfg, want)
+	}
+
+	if sub := c.Sub("cache"); sub != nil {
+		t.Errorf("Sub() = %v, want nil for a missing key", sub)
+	}
+}
+
+func TestConflictingOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []Option
+		wantErr string
+	}{
+		{
+			name:    "config file and file name",
+			opts:    []Option{WithConfigFile("config.yaml"), WithFileName("config")},
+			wantErr: "conflicting options WithConfigFile and WithFileName",
+		},
+		{
+			name:    "file name and config file",
+			opts:    []Option{WithFileName("config"), WithConfigFile("config.yaml")},
+			wantErr: "conflicting options WithFileName and WithConfigFile",
+		},
+		{
+			name:    "reader and file type",
+			opts:    []Option{WithReader(strings.NewReader(""), "yaml"), WithFileType("json")},
+			wantErr: "conflicting options WithReader and WithFileType",
+		},
+		{
+			name: "file name and file path",
+			opts: []Option{WithFilePath(t.TempDir()), WithFileName("config")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := New(tt.opts...).Err()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Err() = %v, want nil", err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Err() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	type config struct {
+		Host string `env:"host" validate:"required"`
+		Port int    `env:"port"`
+		Name string `env:"name" validate:"required"`
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte("host: file\nport: 8080"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	opts := []Option{WithFilePath(dir), WithFileName("config")}
+
+	t.Run("file and env", func(t *testing.T) {
+		t.Setenv("NAME", "env")
+
+		var cfg config
+		if err := Load(&cfg, opts...); err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+
+		want := config{Host: "file", Port: 8080, Name: "env"}
+		if cfg != want {
+			t.Errorf("Load() = %+v, want %+v", cfg, want)
+		}
+	})
+
+	t.Run("validation error", func(t *testing.T) {
+		err := Load(&config{}, opts...)
+
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) || !strings.Contains(err.Error(), "field 'name' is required") {
+			t.Errorf("Load() error = %v, want the validation error of name", err)
+		}
+	})
+
+	t.Run("read error", func(t *testing.T) {
+		if err := Load(&config{}, WithConfigFile(filepath.Join(dir, "missing.yaml"))); err == nil {
+			t.Error("Load() error = nil, want the read error")
+		}
+	})
+}
+
+func TestPostProcess(t *testing.T) {
+	type config struct {
+		Level string `env:"level" default:"info" validate:"oneof=DEBUG INFO"`
+	}
+
+	upper := WithPostProcess(func(v interface{}) error {
+		cfg := v.(*config)
+		cfg.Level = strings.ToUpper(cfg.Level)
+		return nil
+	})
+
+	t.Run("uppercases before validating", func(t *testing.T) {
+		var cfg config
+		if err := newYAML(t, "", upper).Unmarshal(&cfg); err != nil {
+			t.Fatalf("Unmarshal() error = %v", err)
+		}
+		if cfg.Level != "INFO" {
+			t.Errorf("Level = %q, want %q", cfg.Level, "INFO")
+		}
+	})
+
+	t.Run("without the post-processor", func(t *testing.T) {
+		if err := newYAML(t, "").Unmarshal(&config{}); err == nil {
+			t.Error("Unmarshal() error = nil, want the oneof error")
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		errPostProcess := errors.New("post-process failed")
+		fail := WithPostProcess(func(interface{}) error { return errPostProcess })
+
+		if err := newYAML(t, "", upper, fail).Unmarshal(&config{}); !errors.Is(err, errPostProcess) {
+			t.Errorf("Unmarshal() error = %v, want %v", err, errPostProcess)
+		}
+	})
+}
+
+func TestDotEnvFile(t *testing.T) {
+	type config struct {
+		Name string `env:"dotenv_name"`
+		Host string `env:"dotenv_host"`
+	}
+
+	path := filepath.Join(t.TempDir(), ".env.local")
+	if err := os.WriteFile(path, []byte("DOTENV_NAME=file\nDOTENV_HOST=file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The variables set by the file are removed once the test ends
+	t.Setenv("DOTENV_NAME", "")
+	os.Unsetenv("DOTENV_NAME")
+	t.Setenv("DOTENV_HOST", "env")
+
+	var cfg config
+	if err := newYAML(t, "", WithDotEnvFile(path), WithDotEnvFile(filepath.Join(t.TempDir(), "missing"))).Unmarshal(&cfg); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	want := config{Name: "file", Host: "env"}
+	if cfg != want {
+		t.Errorf("Unmarshal() = %+v, want %+v", cfg, want)
+	}
+	if got := os.Getenv("DOTENV_HOST"); got != "env" {
+		t.Errorf("DOTENV_HOST = %q, want the existing value %q", got, "env")
+	}
+}
+
+func TestSetDefaults(t *testing.T) {
+	type config struct {
+		Port int `env:"port"`
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "app.json"), []byte(`{"port": 8080}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	SetDefaults(dir, "app", "json")
+	t.Cleanup(func() { SetDefaults(defaultFilePath, defaultFileName, defaultFileType) })
+
+	c := New()
+	if err := c.Err(); err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	var cfg config
+	if err := c.Unmarshal(&cfg); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if cfg.Port != 8080 {
+		t.Errorf("Port = %d, want 8080", cfg.Port)
+	}
+	if want := filepath.Join(dir, "app.json"); c.ConfigFileUsed() != want {
+		t.Errorf("ConfigFileUsed() = %q, want %q", c.ConfigFileUsed(), want)
+	}
+}
+
+func TestObserver(t *testing.T) {
+	type config struct {
+		Host string `env:"host" default:"localhost" validate:"required"`
+	}
+
+	var stages []string
+	observer := WithObserver(func(stage string, d time.Duration) {
+		if d < 0 {
+			t.Errorf("observer(%q) duration = %v, want >= 0", stage, d)
+		}
+		stages = append(stages, stage)
+	})
+
+	c := newYAML(t, "host: file", observer)
+	if err := c.Unmarshal(&config{}); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	want := []string{"read", "env-bind", "decode", "defaults", "validate"}
+	if !reflect.DeepEqual(stages, want) {
+		t.Errorf("stages = %v, want %v", stages, want)
+	}
+}
+
+func TestMergeFile(t *testing.T) {
+	type config struct {
+		Database struct {
+			Host     string `env:"host"`
+			Port     int    `env:"port"`
+			Password string `env:"password"`
+		} `env:"database"`
+	}
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "config.yaml"), "database:\n  host: localhost\n  port: 5432\n  password: base")
+	writeFile(t, filepath.Join(dir, "secrets.toml"), "[database]\npassword = \"secret\"\nport = 5433\n")
+	writeFile(t, filepath.Join(dir, "secrets.conf"), "[database]\npassword = \"conf\"\n")
+
+	tests := []struct {
+		name     string
+		opts     []Option
+		wantPort int
+		wantPass string
+		wantErr  bool
+	}{
+		{name: "inferred type", opts: []Option{WithMergeFile(filepath.Join(dir, "secrets.toml"), "")}, wantPort: 5433, wantPass: "secret"},
+		{name: "explicit type", opts: []Option{WithMergeFile(filepath.Join(dir, "secrets.conf"), "toml")}, wantPort: 5432, wantPass: "conf"},
+		{
+			name:     "later file wins",
+			opts:     []Option{WithMergeFile(filepath.Join(dir, "secrets.toml"), ""), WithMergeFile(filepath.Join(dir, "secrets.conf"), "toml")},
+			wantPort: 5433,
+			wantPass: "conf",
+		},
+		{name: "missing file", opts: []Option{WithMergeFile(filepath.Join(dir, "missing.toml"), "")}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(append([]Option{WithFilePath(dir), WithFileName("config")}, tt.opts...)...)
+			if err := c.Err(); (err != nil) != tt.wantErr {
+				t.Fatalf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			var cfg config
+			if err := c.Unmarshal(&cfg); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+
+			db := cfg.Database
+			if db.Host != "localhost" || db.Port != tt.wantPort || db.Password != tt.wantPass {
+				t.Errorf("Database = %+v, want the base host, port %d and password %q", db, tt.wantPort, tt.wantPass)
+			}
+		})
+	}
+}
+
+func TestCollectAllErrors(t *testing.T) {
+	type config struct {
+		Host string `env:"host" validate:"required"`
+		Port int    `env:"port"`
+	}
+
+	t.Run("all errors", func(t *testing.T) {
+		err := newYAML(t, "port: 8080\ntimout: 5", WithStrictDecoding(), WithCollectAllErrors()).Unmarshal(&config{})
+		if err == nil {
+			t.Fatal("Unmarshal() error = nil, want the joined errors")
+		}
+
+		for _, want := range []string{"has invalid keys: timout", "field 'host' is required"} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("Unmarshal() error = %v, want %q", err, want)
+			}
+		}
+
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) {
+			t.Errorf("Unmarshal() error = %v, want a *ValidationError joined", err)
+		}
+	})
+
+	t.Run("first error", func(t *testing.T) {
+		err := newYAML(t, "port: 8080\ntimout: 5", WithStrictDecoding()).Unmarshal(&config{})
+		if err == nil || strings.Contains(err.Error(), "field 'host' is required") {
+			t.Errorf("Unmarshal() error = %v, want only the strict error", err)
+		}
+	})
+}
+
+func TestUnmarshalKey(t *testing.T) {
+	type DBConfig struct {
+		Host string `env:"host" validate:"required"`
+		Port int    `env:"port" default:"5432"`
+	}
+
+	tests := []struct {
+		name    string
+		yaml    string
+		want    DBConfig
+		wantErr string
+	}{
+		{name: "section", yaml: "database:\n  host: db", want: DBConfig{Host: "db", Port: 5432}},
+		{name: "validation error", yaml: "database:\n  port: 5433", wantErr: "field 'host' is required"},
+		{name: "missing section", yaml: "port: 8080", wantErr: "field 'host' is required"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg DBConfig
+			err := newYAML(t, tt.yaml).UnmarshalKey("database", &cfg)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("UnmarshalKey() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("UnmarshalKey() error = %v", err)
+			}
+			if cfg != tt.want {
+				t.Errorf("UnmarshalKey() = %+v, want %+v", cfg, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithSettings(t *testing.T) {
+	type config struct {
+		Name     string `env:"name"`
+		Database struct {
+			Host string `env:"host"`
+			Port int    `env:"port"`
+			Pool struct {
+				Size int `env:"size"`
+			} `env:"pool"`
+		} `env:"database"`
+	}
+
+	settings := map[string]interface{}{
+		"name": "app",
+		"database": map[string]interface{}{
+			"host": "localhost",
+			"port": 5432,
+			"pool": map[string]interface{}{"size": 10},
+		},
+	}
+	t.Setenv("DATABASE_HOST", "env")
+
+	c := New(WithSettings(settings))
+	if err := c.Err(); err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	var cfg config
+	if err := c.Unmarshal(&cfg); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	if cfg.Name != "app" || cfg.Database.Host != "env" || cfg.Database.Port != 5432 || cfg.Database.Pool.Size != 10 {
+		t.Errorf("Unmarshal() = %+v, want the map values and the env host", cfg)
+	}
+	if host := settings["database"].(map[string]interface{})["host"]; host != "localhost" {
+		t.Errorf("settings host = %v, want the map unchanged", host)
+	}
+}
+
+func TestFreeze(t *testing.T) {
+	c := newYAML(t, "port: 8080")
+	if err := c.Set("port", 9090); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	c.Freeze()
+
+	changes := []struct {
+		name   string
+		change func() error
+	}{
+		{name: "Set", change: func() error { return c.Set("port", 7070) }},
+		{name: "Reset", change: c.Reset},
+		{name: "BindPFlags", change: func() error { return c.BindPFlags(pflag.NewFlagSet("test", pflag.ContinueOnError)) }},
+		{name: "RegisterAlias", change: func() error { return c.RegisterAlias("legacy_port", "port") }},
+	}
+	for _, tt := range changes {
+		if err := tt.change(); !errors.Is(err, ErrFrozen) {
+			t.Errorf("%s() error = %v, want %v", tt.name, err, ErrFrozen)
+		}
+	}
+
+	if port := c.GetInt("port"); port != 9090 {
+		t.Errorf("GetInt() = %d, want the value set before freezing", port)
+	}
+
+	clone := c.Clone()
+	if err := clone.Set("port", 7070); err != nil {
+		t.Errorf("clone Set() error = %v, want the clone not frozen", err)
+	}
+}
+
+func TestPointerFields(t *testing.T) {
+	type config struct {
+		MaxConns *int `env:"max_conns"`
+		Timeout  *int `env:"timeout" default:"30"`
+	}
+
+	tests := []struct {
+		name        string
+		yaml        string
+		env         string
+		wantConns   *int
+		wantTimeout int
+	}{
+		{name: "unset", wantTimeout: 30},
+		{name: "file", yaml: "max_conns: 10\ntimeout: 5", wantConns: intPtr(10), wantTimeout: 5},
+		{name: "zero", yaml: "max_conns: 0", wantConns: intPtr(0), wantTimeout: 30},
+		{name: "env", env: "20", wantConns: intPtr(20), wantTimeout: 30},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.env != "" {
+				t.Setenv("MAX_CONNS", tt.env)
+			}
+
+			var cfg config
+			if err := newYAML(t, tt.yaml).Unmarshal(&cfg); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(cfg.MaxConns, tt.wantConns) {
+				t.Errorf("MaxConns = %v, want %v", cfg.MaxConns, tt.wantConns)
+			}
+			if cfg.Timeout == nil || *cfg.Timeout != tt.wantTimeout {
+				t.Errorf("Timeout = %v, want a pointer to %d", cfg.Timeout, tt.wantTimeout)
+			}
+		})
+	}
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func TestYAMLAnchors(t *testing.T) {
+	type database struct {
+		Host string `env:"host"`
+		Port int    `env:"port"`
+		Pool int    `env:"pool"`
+	}
+	type config struct {
+		Primary database `env:"primary"`
+		Replica database `env:"replica"`
+	}
+
+	yaml := `
+base: &base
+  port: 5432
+  pool: 10
+primary:
+  <<: *base
+  host: primary.db
+replica:
+  <<: *base
+  host: replica.db
+  pool: 5
+`
+
+	var cfg config
+	if err := newYAML(t, yaml).Unmarshal(&cfg); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	want := config{
+		Primary: database{Host: "primary.db", Port: 5432, Pool: 10},
+		Replica: database{Host: "replica.db", Port: 5432, Pool: 5},
+	}
+	if cfg != want {
+		t.Errorf("Unmarshal() = %+v, want %+v", cfg, want)
+	}
+}
+
+func TestEmptyFile(t *testing.T) {
+	type config struct {
+		Host     string `env:"host" default:"localhost"`
+		Port     int    `env:"port" default:"8080"`
+		Database struct {
+			Name string `env:"name" default:"app"`
+		} `env:"database"`
+	}
+
+	for _, content := range []string{"", "# only a comment\n"} {
+		dir := t.TempDir()
+		writeFile(t, filepath.Join(dir, "config.yaml"), content)
+
+		c := New(WithFilePath(dir), WithFileName("config"))
+		if err := c.Err(); err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+
+		var cfg config
+		if err := c.Unmarshal(&cfg); err != nil {
+			t.Fatalf("Unmarshal() error = %v", err)
+		}
+		if cfg.Host != "localhost" || cfg.Port != 8080 || cfg.Database.Name != "app" {
+			t.Errorf("Unmarshal() = %+v for %q, want the default values", cfg, content)
+		}
+	}
+}
+
+func TestWithViper(t *testing.T) {
+	type config struct {
+		Name     string `env:"name" validate:"required"`
+		Database struct {
+			Host string `env:"host"`
+			Port int    `env:"port" default:"5432"`
+		} `env:"database"`
+	}
+
+	v := viper.New()
+	v.Set("name", "app")
+	v.Set("database.host", "db")
+	v.RegisterAlias("db_host", "database.host")
+
+	c := New(WithViper(v))
+	if err := c.Err(); err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	var cfg config
+	if err := c.Unmarshal(&cfg); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if cfg.Name != "app" || cfg.Database.Host != "db" || cfg.Database.Port != 5432 {
+		t.Errorf("Unmarshal() = %+v, want the viper values and the default port", cfg)
+	}
+	if host := c.GetString("db_host"); host != "db" {
+		t.Errorf("GetString() = %q, want the alias of the viper instance", host)
+	}
+
+	if err := New(WithViper(viper.New())).Unmarshal(&config{}); err == nil || !strings.Contains(err.Error(), "field 'name' is required") {
+		t.Errorf("Unmarshal() error = %v, want the validation error", err)
+	}
+}
@@ -0,0 +1,45 @@
+package config
+
+import "fmt"
+
+// deprecatedKey is a key marked as deprecated with MarkDeprecated.
+type deprecatedKey struct {
+	key     string
+	message string
+}
+
+// MarkDeprecated marks the key as deprecated, if it is set in the configuration
+// Unmarshal records a warning with the message, e.g. the name of the key that
+// replaces it, instead of failing. Nested keys use dotted notation.
+func (c *Config) MarkDeprecated(key, message string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.deprecatedKeys = append(c.deprecatedKeys, deprecatedKey{key: key, message: message})
+}
+
+// Warnings returns the warnings recorded by the last Unmarshal, e.g. the
+// deprecated keys still in use.
+func (c *Config) Warnings() []string {
+	c.warningsMu.Lock()
+	defer c.warningsMu.Unlock()
+
+	return c.warnings
+}
+
+// checkDeprecatedKeys records a warning for each deprecated key present in the
+// settings.
+func (c *Config) checkDeprecatedKeys(settings map[string]interface{}) {
+	var warnings []string
+	for _, d := range c.deprecatedKeys {
+		if lookup(settings, d.key) == nil {
+			continue
+		}
+
+		warnings = append(warnings, fmt.Sprintf("key '%s' is deprecated: %s", d.key, d.message))
+	}
+
+	c.warningsMu.Lock()
+	c.warnings = warnings
+	c.warningsMu.Unlock()
+}
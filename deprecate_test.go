@@ -0,0 +1,29 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMarkDeprecated(t *testing.T) {
+	type config struct {
+		Port     int `env:"port"`
+		Database struct {
+			Host string `env:"host"`
+		} `env:"database"`
+	}
+
+	c := newYAML(t, "port: 8080\ndatabase:\n  hostname: db")
+	c.MarkDeprecated("database.hostname", "use database.host")
+	c.MarkDeprecated("listen_port", "use port")
+
+	var cfg config
+	if err := c.Unmarshal(&cfg); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	want := []string{"key 'database.hostname' is deprecated: use database.host"}
+	if got := c.Warnings(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Warnings() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,46 @@
+package config
+
+import (
+	"reflect"
+)
+
+// Diff returns the keys whose values changed between the prev and curr
+// settings, e.g. the ones returned by Settings before and after a reload, with
+// their old and new values. The nested sections are walked, so the keys are
+// the dotted paths of the leaves, e.g. `database.host`. The keys added or
+// removed have a nil old or new value respectively.
+func Diff(prev, curr map[string]interface{}) map[string][2]interface{} {
+	prevLeaves := flattenSettings("", prev, make(map[string]interface{}))
+	currLeaves := flattenSettings("", curr, make(map[string]interface{}))
+
+	diff := make(map[string][2]interface{})
+	for key, prevValue := range prevLeaves {
+		currValue, ok := currLeaves[key]
+		if !ok || !reflect.DeepEqual(prevValue, currValue) {
+			diff[key] = [2]interface{}{prevValue, currValue}
+		}
+	}
+
+	for key, currValue := range currLeaves {
+		if _, ok := prevLeaves[key]; !ok {
+			diff[key] = [2]interface{}{nil, currValue}
+		}
+	}
+
+	return diff
+}
+
+// flattenSettings adds the leaves of the settings to leaves with their dotted
+// paths as keys.
+func flattenSettings(prefix string, settings map[string]interface{}, leaves map[string]interface{}) map[string]interface{} {
+	for key, value := range settings {
+		if section, ok := value.(map[string]interface{}); ok {
+			flattenSettings(prefix+key+".", section, leaves)
+			continue
+		}
+
+		leaves[prefix+key] = value
+	}
+
+	return leaves
+}
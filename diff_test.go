@@ -0,0 +1,70 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	prev := map[string]interface{}{
+		"port": 8080,
+		"tags": []interface{}{"a", "b"},
+		"database": map[string]interface{}{
+			"host": "localhost",
+			"port": 5432,
+			"pool": map[string]interface{}{"size": 10},
+		},
+		"debug": true,
+	}
+
+	tests := []struct {
+		name string
+		curr map[string]interface{}
+		want map[string][2]interface{}
+	}{
+		{
+			name: "unchanged",
+			curr: prev,
+			want: map[string][2]interface{}{},
+		},
+		{
+			name: "nested leaf changed",
+			curr: map[string]interface{}{
+				"port": 8080,
+				"tags": []interface{}{"a", "b"},
+				"database": map[string]interface{}{
+					"host": "db.example.com",
+					"port": 5432,
+					"pool": map[string]interface{}{"size": 10},
+				},
+				"debug": true,
+			},
+			want: map[string][2]interface{}{"database.host": {"localhost", "db.example.com"}},
+		},
+		{
+			name: "added and removed",
+			curr: map[string]interface{}{
+				"port": 8080,
+				"tags": []interface{}{"a", "b", "c"},
+				"database": map[string]interface{}{
+					"host": "localhost",
+					"port": 5432,
+					"pool": map[string]interface{}{"size": 10, "idle": 2},
+				},
+			},
+			want: map[string][2]interface{}{
+				"tags":               {[]interface{}{"a", "b"}, []interface{}{"a", "b", "c"}},
+				"database.pool.idle": {nil, 2},
+				"debug":              {true, nil},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Diff(prev, tt.curr); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
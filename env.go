@@ -0,0 +1,318 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// BindEnvs binds the keys of the config structure fields to their environment
+// variables, like Unmarshal does, so Get, Settings and IsSet read the
+// environment variables of the keys the config file doesn't set before the
+// first Unmarshal, e.g. `DATABASE_HOST` for `database.host`.
+func (c *Config) BindEnvs(config interface{}) error {
+	if err := checkConfig(config); err != nil {
+		return err
+	}
+
+	c.bindEnvs(config)
+
+	return nil
+}
+
+// bindEnvs binds the keys of the config structure fields to their environment
+// variables, since viper only reads the environment variables of the keys it
+// already knows, e.g. from the config file. So `server.tls.certfile` is read
+// from `SERVER_TLS_CERTFILE` even if the config file doesn't set it. The fields
+// with an `envvar` tag are read from the environment variable it names instead.
+func (c *Config) bindEnvs(config interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, field := range c.structKeys("", reflect.TypeOf(config).Elem(), nil) {
+		if _, ok := c.boundEnvKeys[field.key]; ok {
+			continue
+		}
+
+		name := field.envVar
+		if name == "" {
+			name = c.envName(c.keyPrefix + field.key)
+		}
+
+		if c.boundEnvKeys == nil {
+			c.boundEnvKeys = make(map[string]string)
+		}
+		c.boundEnvKeys[field.key] = name
+		c.v.BindEnv(field.key, name)
+	}
+}
+
+// fieldKey is the key of a leaf field of the config structure.
+type fieldKey struct {
+	// key is the dotted key of the field, e.g. `database.host`.
+	key string
+
+	// envVar is the environment variable set by the `envvar` tag, if any.
+	envVar string
+}
+
+// structKeys returns the dotted keys of the leaf fields of the struct type,
+// walking the nested structures. The types being walked are skipped so the
+// recursive types end.
+func (c *Config) structKeys(prefix string, t reflect.Type, walking []reflect.Type) []fieldKey {
+	for _, w := range walking {
+		if w == t {
+			return nil
+		}
+	}
+	walking = append(walking, t)
+
+	var keys []fieldKey
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+
+		isStruct := fieldType.Kind() == reflect.Struct && !isValueType(fieldType)
+
+		// The embedded structs are flattened
+		if field.Anonymous && isStruct {
+			keys = append(keys, c.structKeys(prefix, fieldType, walking)...)
+			continue
+		}
+
+		name := c.keyName(field)
+		if name == "" {
+			continue
+		}
+
+		key := prefix + strings.ToLower(name)
+		if isStruct {
+			keys = append(keys, c.structKeys(key+".", fieldType, walking)...)
+			continue
+		}
+
+		keys = append(keys, fieldKey{key: key, envVar: field.Tag.Get("envvar")})
+	}
+
+	return keys
+}
+
+// applyFileEnv sets the keys of the config structure fields with the trimmed
+// content of the file named by their environment variable with the `_FILE`
+// suffix, e.g. `password` with the content of `PASSWORD_FILE`, unless their
+// environment variable is set. The values set with Set take precedence.
+func (c *Config) applyFileEnv(settings map[string]interface{}) error {
+	keys := make([]string, 0, len(c.boundEnvKeys))
+	for key := range c.boundEnvKeys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if _, ok := c.overrides[key]; ok {
+			continue
+		}
+
+		name := c.envKeyReplacer.Replace(c.boundEnvKeys[key])
+		if os.Getenv(name) != "" {
+			continue
+		}
+
+		file := os.Getenv(name + "_FILE")
+		if file == "" {
+			continue
+		}
+
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("environment variable %s_FILE: %w", name, err)
+		}
+
+		setKey(settings, key, strings.TrimSpace(string(data)))
+	}
+
+	return nil
+}
+
+// setKey sets the value of the dotted key in the settings, creating the
+// missing sections.
+func setKey(settings map[string]interface{}, key string, value interface{}) {
+	parts := strings.Split(key, ".")
+	for _, part := range parts[:len(parts)-1] {
+		section, ok := settings[part].(map[string]interface{})
+		if !ok {
+			section = make(map[string]interface{})
+			settings[part] = section
+		}
+		settings = section
+	}
+
+	settings[parts[len(parts)-1]] = value
+}
+
+// deleteKey deletes the dotted key from the settings, and the sections left
+// empty.
+func deleteKey(settings map[string]interface{}, key string) {
+	part, rest, nested := strings.Cut(key, ".")
+	if !nested {
+		delete(settings, part)
+		return
+	}
+
+	section, ok := settings[part].(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	deleteKey(section, rest)
+	if len(section) == 0 {
+		delete(settings, part)
+	}
+}
+
+// expandEnv replaces the references to environment variables in the string
+// values of the settings, e.g. `${HOME}/logs`, walking the nested sections and
+// lists. The undefined variables are replaced by an empty string, or are an
+// error with WithStrictEnvExpansion. Only the values of the config files and
+// sources are expanded, the values of the environment variables, the flags and
+// Set are kept as given, e.g. a password holding a `$`.
+func (c *Config) expandEnv(prefix string, settings map[string]interface{}) error {
+	for k, v := range settings {
+		if c.overridden(prefix + k) {
+			continue
+		}
+
+		value, err := c.expandEnvValue(prefix+k, v)
+		if err != nil {
+			return err
+		}
+		settings[k] = value
+	}
+
+	return nil
+}
+
+// expandEnvValue returns the value of the key with the environment variables
+// of its strings expanded.
+func (c *Config) expandEnvValue(key string, value interface{}) (interface{}, error) {
+	switch value := value.(type) {
+	case string:
+		var undefined []string
+		expanded := os.Expand(value, func(name string) string {
+			v, ok := os.LookupEnv(name)
+			if !ok {
+				undefined = append(undefined, name)
+			}
+
+			return v
+		})
+		if c.strictEnvExpansion && len(undefined) > 0 {
+			return nil, fmt.Errorf("undefined environment variable %s in key '%s'", undefined[0], key)
+		}
+
+		return expanded, nil
+	case map[string]interface{}:
+		return value, c.expandEnv(key+".", value)
+	case []interface{}:
+		elems := make([]interface{}, len(value))
+		for i, elem := range value {
+			v, err := c.expandEnvValue(fmt.Sprintf("%s[%d]", key, i), elem)
+			if err != nil {
+				return nil, err
+			}
+			elems[i] = v
+		}
+
+		return elems, nil
+	}
+
+	return value, nil
+}
+
+// applySliceEnv overrides the elements of the slices in the settings with the
+// environment variables of their index, e.g. the second element of `hosts`
+// with `HOSTS_1`, walking the nested sections. An index out of range is an
+// error unless the slices are extended with WithSliceEnvExtend.
+func (c *Config) applySliceEnv(prefix string, settings map[string]interface{}) error {
+	for k, v := range settings {
+		key := prefix + k
+		if m, ok := v.(map[string]interface{}); ok {
+			if err := c.applySliceEnv(key+".", m); err != nil {
+				return err
+			}
+			continue
+		}
+
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Slice || rv.Type().Elem().Kind() == reflect.Uint8 {
+			continue
+		}
+
+		elems, err := c.sliceEnv(key, rv)
+		if err != nil {
+			return err
+		}
+		if elems != nil {
+			settings[k] = elems
+		}
+	}
+
+	return nil
+}
+
+// sliceEnv returns a copy of the slice of the key with the elements overridden
+// by the environment variables, or nil if none is set.
+func (c *Config) sliceEnv(key string, slice reflect.Value) ([]interface{}, error) {
+	name := c.envKeyReplacer.Replace(c.envName(c.keyPrefix + key + "."))
+
+	overrides := make(map[int]string)
+	var indexes []int
+	for _, env := range os.Environ() {
+		envName, value, _ := strings.Cut(env, "=")
+		if value == "" || !strings.HasPrefix(envName, name) {
+			continue
+		}
+
+		i, err := strconv.Atoi(strings.TrimPrefix(envName, name))
+		if err != nil || i < 0 {
+			continue
+		}
+
+		overrides[i] = value
+		indexes = append(indexes, i)
+	}
+
+	if len(indexes) == 0 {
+		return nil, nil
+	}
+	sort.Ints(indexes)
+
+	elems := make([]interface{}, slice.Len())
+	for i := range elems {
+		elems[i] = slice.Index(i).Interface()
+	}
+
+	for _, i := range indexes {
+		if i >= len(elems) {
+			if !c.sliceEnvExtend {
+				return nil, fmt.Errorf("environment variable %s%d is out of range, the key '%s' has %d elements", name, i, key, len(elems))
+			}
+
+			elems = append(elems, make([]interface{}, i+1-len(elems))...)
+		}
+
+		elems[i] = overrides[i]
+	}
+
+	return elems, nil
+}
@@ -0,0 +1,395 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSliceFromEnv(t *testing.T) {
+	type config struct {
+		Hosts []string `env:"hosts"`
+		Ports []int    `env:"ports"`
+	}
+
+	tests := []struct {
+		name  string
+		hosts string
+		ports string
+		opts  []Option
+		want  config
+	}{
+		{name: "comma separated", hosts: "a,b,c", ports: "80,443", want: config{Hosts: []string{"a", "b", "c"}, Ports: []int{80, 443}}},
+		{name: "single value", hosts: "a", ports: "80", want: config{Hosts: []string{"a"}, Ports: []int{80}}},
+		{name: "custom separator", hosts: "a;b", ports: "80;443", opts: []Option{WithSliceSeparator(";")}, want: config{Hosts: []string{"a", "b"}, Ports: []int{80, 443}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SLICES_HOSTS", tt.hosts)
+			t.Setenv("SLICES_PORTS", tt.ports)
+
+			var cfg config
+			if err := newYAML(t, "", append(tt.opts, WithEnvPrefix("SLICES"))...).Unmarshal(&cfg); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(cfg, tt.want) {
+				t.Errorf("config = %+v, want %+v", cfg, tt.want)
+			}
+		})
+	}
+}
+
+func TestSliceIndexEnv(t *testing.T) {
+	type config struct {
+		Hosts   []string `env:"hosts"`
+		Servers []struct {
+			Host string `env:"host"`
+		} `env:"servers"`
+	}
+
+	yaml := "hosts:\n  - a\n  - b\nservers:\n  - host: s0\n  - host: s1"
+
+	tests := []struct {
+		name      string
+		env       map[string]string
+		opts      []Option
+		wantHosts []string
+		wantErr   bool
+	}{
+		{name: "in range", env: map[string]string{"INDEX_HOSTS_1": "z"}, wantHosts: []string{"a", "z"}},
+		{name: "out of range", env: map[string]string{"INDEX_HOSTS_3": "z"}, wantErr: true},
+		{name: "extended", env: map[string]string{"INDEX_HOSTS_3": "z"}, opts: []Option{WithSliceEnvExtend()}, wantHosts: []string{"a", "b", "", "z"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			var cfg config
+			err := newYAML(t, yaml, append(tt.opts, WithEnvPrefix("INDEX"))...).Unmarshal(&cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unmarshal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if !reflect.DeepEqual(cfg.Hosts, tt.wantHosts) {
+				t.Errorf("Hosts = %q, want %q", cfg.Hosts, tt.wantHosts)
+			}
+			if len(cfg.Servers) != 2 || cfg.Servers[0].Host != "s0" || cfg.Servers[1].Host != "s1" {
+				t.Errorf("Servers = %+v, want the servers of the file", cfg.Servers)
+			}
+		})
+	}
+}
+
+func TestEnvExpansion(t *testing.T) {
+	type config struct {
+		LogDir   string `env:"log_dir"`
+		Password string `env:"password"`
+	}
+
+	t.Setenv("HOME", "/home/app")
+
+	tests := []struct {
+		name    string
+		yaml    string
+		env     map[string]string
+		opts    []Option
+		want    config
+		wantErr string
+	}{
+		{
+			name: "file value",
+			yaml: "log_dir: ${HOME}/logs",
+			opts: []Option{WithEnvExpansion()},
+			want: config{LogDir: "/home/app/logs"},
+		},
+		{
+			name: "without expansion",
+			yaml: "log_dir: ${HOME}/logs",
+			want: config{LogDir: "${HOME}/logs"},
+		},
+		{
+			name: "env secret",
+			yaml: "log_dir: ${HOME}/logs\npassword: file",
+			env:  map[string]string{"PASSWORD": "pa$$w0rd"},
+			opts: []Option{WithEnvExpansion()},
+			want: config{LogDir: "/home/app/logs", Password: "pa$$w0rd"},
+		},
+		{
+			name: "env secret, strict",
+			yaml: "log_dir: ${HOME}/logs",
+			env:  map[string]string{"PASSWORD": "pa$word"},
+			opts: []Option{WithStrictEnvExpansion()},
+			want: config{LogDir: "/home/app/logs", Password: "pa$word"},
+		},
+		{
+			name:    "undefined variable, strict",
+			yaml:    "log_dir: ${APP_UNDEFINED}/logs",
+			opts:    []Option{WithStrictEnvExpansion()},
+			wantErr: "APP_UNDEFINED",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			c := New(append([]Option{WithReader(strings.NewReader(tt.yaml), "yaml")}, tt.opts...)...)
+
+			var cfg config
+			err := c.Unmarshal(&cfg)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("Unmarshal() error = %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Fatalf("Unmarshal() error = %v, want %q", err, tt.wantErr)
+			case tt.wantErr == "" && cfg != tt.want:
+				t.Errorf("config = %+v, want %+v", cfg, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnvPrefix(t *testing.T) {
+	type config struct {
+		Name     string `env:"name"`
+		Database struct {
+			Name string `env:"name"`
+			Host string `env:"host"`
+			Port int    `env:"port"`
+		} `env:"database"`
+	}
+
+	t.Setenv("MYAPP_NAME", "app")
+	t.Setenv("MYAPP_DATABASE_HOST", "db")
+	t.Setenv("DATABASE_PORT", "5432")
+
+	c := newYAML(t, "database:\n  port: 3306", WithEnvPrefix("MYAPP"), WithGlobalEnvPropagation(true))
+
+	var cfg config
+	if err := c.Unmarshal(&cfg); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	if cfg.Database.Host != "db" {
+		t.Errorf("Database.Host = %q, want %q from MYAPP_DATABASE_HOST", cfg.Database.Host, "db")
+	}
+	if cfg.Database.Port != 3306 {
+		t.Errorf("Database.Port = %d, want 3306, the variable without prefix is ignored", cfg.Database.Port)
+	}
+
+	// The prefixed global variable is propagated into the sections
+	if cfg.Name != "app" || cfg.Database.Name != "app" {
+		t.Errorf("Name = %q, Database.Name = %q, want %q from MYAPP_NAME", cfg.Name, cfg.Database.Name, "app")
+	}
+}
+
+func TestEnvKeyReplacer(t *testing.T) {
+	type config struct {
+		Database struct {
+			Host string `env:"host"`
+		} `env:"database"`
+	}
+
+	t.Setenv("DATABASE__HOST", "db")
+	t.Setenv("DATABASE_HOST", "single")
+
+	c := newYAML(t, "database:\n  host: file", WithEnvKeyReplacer(".", "__"))
+
+	var cfg config
+	if err := c.Unmarshal(&cfg); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	if cfg.Database.Host != "db" {
+		t.Errorf("Database.Host = %q, want %q from DATABASE__HOST", cfg.Database.Host, "db")
+	}
+	if got := c.GetString("database.host"); got != "db" {
+		t.Errorf("GetString() = %q, want %q", got, "db")
+	}
+}
+
+func TestNestedEnvOnly(t *testing.T) {
+	type config struct {
+		Server struct {
+			TLS struct {
+				CertFile string `env:"certfile"`
+			} `env:"tls"`
+		} `env:"server"`
+	}
+
+	t.Setenv("SERVER_TLS_CERTFILE", "/etc/tls/cert.pem")
+
+	var cfg config
+	if err := newYAML(t, "port: 8080").Unmarshal(&cfg); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	if got := cfg.Server.TLS.CertFile; got != "/etc/tls/cert.pem" {
+		t.Errorf("Server.TLS.CertFile = %q, want %q", got, "/etc/tls/cert.pem")
+	}
+}
+
+func TestEmptyAsUnset(t *testing.T) {
+	type config struct {
+		Host string `env:"host" default:"localhost"`
+	}
+
+	tests := []struct {
+		name string
+		yaml string
+		env  string
+		opts []Option
+		want string
+	}{
+		{name: "empty env", env: "", opts: []Option{WithEmptyAsUnset()}, want: "localhost"},
+		{name: "empty env over the file", yaml: "host: file", env: "", opts: []Option{WithEmptyAsUnset()}, want: "file"},
+		{name: "empty file value", yaml: `host: ""`, opts: []Option{WithEmptyAsUnset()}, want: "localhost"},
+		{name: "empty file value defaults first", yaml: `host: ""`, opts: []Option{WithEmptyAsUnset(), WithDefaultsFirst()}, want: "localhost"},
+		{name: "empty file value defaults first kept", yaml: `host: ""`, opts: []Option{WithDefaultsFirst()}, want: ""},
+		{name: "env set", env: "env", opts: []Option{WithEmptyAsUnset()}, want: "env"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HOST", tt.env)
+
+			var cfg config
+			if err := newYAML(t, tt.yaml, tt.opts...).Unmarshal(&cfg); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if cfg.Host != tt.want {
+				t.Errorf("Host = %q, want %q", cfg.Host, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileEnv(t *testing.T) {
+	type config struct {
+		Password string `env:"password"`
+	}
+
+	dir := t.TempDir()
+	secret := filepath.Join(dir, "db")
+	if err := os.WriteFile(secret, []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		opts    []Option
+		want    string
+		wantErr bool
+	}{
+		{name: "secret file", env: map[string]string{"PASSWORD_FILE": secret}, opts: []Option{WithFileEnv()}, want: "s3cret"},
+		{
+			name: "env var wins",
+			env:  map[string]string{"PASSWORD_FILE": secret, "PASSWORD": "env"},
+			opts: []Option{WithFileEnv()},
+			want: "env",
+		},
+		{name: "disabled", env: map[string]string{"PASSWORD_FILE": secret}, want: "file"},
+		{
+			name:    "missing file",
+			env:     map[string]string{"PASSWORD_FILE": filepath.Join(dir, "missing")},
+			opts:    []Option{WithFileEnv()},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			var cfg config
+			err := newYAML(t, "password: file", tt.opts...).Unmarshal(&cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unmarshal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && cfg.Password != tt.want {
+				t.Errorf("Password = %q, want %q", cfg.Password, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnvVarTag(t *testing.T) {
+	type config struct {
+		Services struct {
+			Store struct {
+				DatabaseURL string `env:"database_url" envvar:"DATABASE_URL"`
+				Name        string `env:"name"`
+			} `env:"store"`
+		} `env:"services"`
+	}
+
+	t.Setenv("DATABASE_URL", "postgres://db")
+	t.Setenv("MYAPP_SERVICES_STORE_NAME", "store")
+
+	var cfg config
+	if err := newYAML(t, "services:\n  store:\n    database_url: file", WithEnvPrefix("MYAPP")).Unmarshal(&cfg); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	store := cfg.Services.Store
+	if store.DatabaseURL != "postgres://db" || store.Name != "store" {
+		t.Errorf("Store = %+v, want the envvar url and the prefixed name", store)
+	}
+}
+
+func TestBindEnvs(t *testing.T) {
+	type config struct {
+		Name     string `env:"name"`
+		Database struct {
+			Host string `env:"host"`
+			Port int    `env:"port"`
+			Pool struct {
+				Size int `env:"size"`
+			} `env:"pool"`
+		} `env:"database"`
+	}
+
+	t.Setenv("NAME", "app")
+	t.Setenv("DATABASE_HOST", "db")
+	t.Setenv("DATABASE_PORT", "5432")
+	t.Setenv("DATABASE_POOL_SIZE", "10")
+
+	c := New(WithFilePath(t.TempDir()), WithFileName("config"))
+	if err := c.Err(); err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if err := c.BindEnvs(&config{}); err != nil {
+		t.Fatalf("BindEnvs() error = %v", err)
+	}
+
+	if !c.IsSet("database.pool.size") || c.GetString("database.host") != "db" {
+		t.Errorf("IsSet() = %t, GetString() = %q, want the env values before Unmarshal", c.IsSet("database.pool.size"), c.GetString("database.host"))
+	}
+
+	var cfg config
+	if err := c.Unmarshal(&cfg); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if cfg.Name != "app" || cfg.Database.Host != "db" || cfg.Database.Port != 5432 || cfg.Database.Pool.Size != 10 {
+		t.Errorf("Unmarshal() = %+v, want every field from the env", cfg)
+	}
+
+	if err := c.BindEnvs(config{}); err == nil {
+		t.Error("BindEnvs() error = nil, want an error for a non pointer")
+	}
+}
@@ -0,0 +1,260 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator"
+	"github.com/mitchellh/mapstructure"
+)
+
+// ErrFrozen is returned by the changes of a frozen configuration.
+var ErrFrozen = errors.New("config is frozen")
+
+// KeyError is the decoding failure of a single key.
+type KeyError struct {
+	// Key is the dotted path of the key, e.g. `database.port`.
+	Key string
+
+	// Value is the value of the key that can't be decoded.
+	Value interface{}
+
+	// Err is the decoding error.
+	Err error
+}
+
+// Error returns the human-readable decoding failure.
+func (e KeyError) Error() string {
+	return fmt.Sprintf("decoding error: key '%s' with %T value %v: %v", e.Key, e.Value, e.Value, e.Err)
+}
+
+// Unwrap returns the decoding error.
+func (e KeyError) Unwrap() error {
+	return e.Err
+}
+
+// DecodeError is returned by Unmarshal when the settings can't be decoded into
+// the config structure, it holds the failure of every key.
+type DecodeError struct {
+	errs []KeyError
+
+	// err is the error returned by the decoder.
+	err error
+}
+
+// newDecodeError creates a DecodeError finding the keys of the settings whose
+// values can't be decoded into the fields of the config structure. The error
+// is returned as it is if none is found.
+func (c *Config) newDecodeError(settings map[string]interface{}, config interface{}, err error) error {
+	errs := c.keyErrors("", settings, reflect.TypeOf(config).Elem())
+	if len(errs) == 0 {
+		return err
+	}
+
+	return &DecodeError{errs: errs, err: err}
+}
+
+// keyErrors decodes the value of every key matching a field of the struct type
+// on its own and returns the failures, walking the nested structures.
+func (c *Config) keyErrors(prefix string, settings map[string]interface{}, t reflect.Type) []KeyError {
+	var errs []KeyError
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+
+		// The embedded structs are flattened
+		if field.Anonymous && fieldType.Kind() == reflect.Struct {
+			errs = append(errs, c.keyErrors(prefix, settings, fieldType)...)
+			continue
+		}
+
+		name := c.keyName(field)
+		if name == "" {
+			continue
+		}
+
+		for k, v := range settings {
+			if !strings.EqualFold(k, name) || v == nil {
+				continue
+			}
+
+			errs = append(errs, c.valueErrors(prefix+k, v, field.Type)...)
+		}
+	}
+
+	return errs
+}
+
+// valueErrors returns the failures decoding the value of the key into the
+// type, walking the nested structures, slices and maps of structures.
+func (c *Config) valueErrors(key string, value interface{}, t reflect.Type) []KeyError {
+	elemType := t
+	if elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		switch {
+		case elemType.Kind() == reflect.Struct:
+			return c.keyErrors(key+".", v, elemType)
+		case elemType.Kind() == reflect.Map && elemType.Key().Kind() == reflect.String:
+			var errs []KeyError
+			for k, elem := range v {
+				errs = append(errs, c.valueErrors(key+"."+k, elem, elemType.Elem())...)
+			}
+
+			return errs
+		}
+	case []interface{}:
+		if elemType.Kind() == reflect.Slice {
+			var errs []KeyError
+			for i, elem := range v {
+				errs = append(errs, c.valueErrors(fmt.Sprintf("%s[%d]", key, i), elem, elemType.Elem())...)
+			}
+
+			return errs
+		}
+	}
+
+	if err := c.decodeConfig(value, reflect.New(t).Interface()); err != nil {
+		return []KeyError{{Key: key, Value: value, Err: c.valueError(value, t)}}
+	}
+
+	return nil
+}
+
+// valueError returns the error of the decode hooks converting the value into
+// the type, if any, since the decoder only reports it as text.
+func (c *Config) valueError(value interface{}, t reflect.Type) error {
+	if _, err := mapstructure.DecodeHookExec(c.typeDecodeHook(), reflect.ValueOf(value), reflect.New(t).Elem()); err != nil {
+		return err
+	}
+
+	return fmt.Errorf("expected %s", t)
+}
+
+// Error returns the decoding failures joined in a single message.
+func (e *DecodeError) Error() string {
+	errorMessages := make([]string, 0, len(e.errs))
+	for _, err := range e.errs {
+		errorMessages = append(errorMessages, err.Error())
+	}
+
+	return fmt.Sprintf("errors: %s", strings.Join(errorMessages, ", "))
+}
+
+// Errors returns the decoding failure of every key.
+func (e *DecodeError) Errors() []KeyError {
+	return e.errs
+}
+
+// Unwrap returns the error returned by the decoder.
+func (e *DecodeError) Unwrap() error {
+	return e.err
+}
+
+// FieldError is the validation failure of a single field.
+type FieldError struct {
+	// Field is the dotted path of the field, e.g. `database.host`, using the
+	// names of the validation tag name.
+	Field string
+
+	// Tag is the validation tag that failed, e.g. `required`.
+	Tag string
+
+	// Param is the parameter of the validation tag, e.g. `dev staging prod`
+	// for `oneof=dev staging prod`.
+	Param string
+
+	// Value is the actual value of the field.
+	Value interface{}
+
+	// message is the failure formatted by the error formatter.
+	message string
+}
+
+// Error returns the human-readable validation failure, formatted with the
+// error formatter set with WithErrorFormatter, if any.
+func (e FieldError) Error() string {
+	if e.message != "" {
+		return e.message
+	}
+
+	// List the allowed values, the tag alone doesn't tell them
+	if e.Tag == "oneof" {
+		return fmt.Sprintf("validation error: field '%s' must be one of [%s], got '%v'", e.Field, e.Param, e.Value)
+	}
+
+	return fmt.Sprintf("validation error: field '%s' is %s", e.Field, e.Tag)
+}
+
+// ValidationError is returned by Unmarshal when the config structure doesn't
+// pass the validation, it holds the failure of every field.
+type ValidationError struct {
+	errs []FieldError
+
+	// err is the error returned by the validator.
+	err validator.ValidationErrors
+}
+
+// newValidationError creates a ValidationError from the validator errors, the
+// failures are formatted with format if it is not nil.
+func newValidationError(errs validator.ValidationErrors, format func(validator.FieldError) string) *ValidationError {
+	e := &ValidationError{err: errs}
+	for _, err := range errs {
+		fieldErr := FieldError{
+			Field: fieldPath(err),
+			Tag:   err.Tag(),
+			Param: err.Param(),
+			Value: err.Value(),
+		}
+		if format != nil {
+			fieldErr.message = format(err)
+		}
+
+		e.errs = append(e.errs, fieldErr)
+	}
+
+	return e
+}
+
+// fieldPath returns the namespace of the field without the name of the root
+// structure, e.g. `database.host`.
+func fieldPath(err validator.FieldError) string {
+	ns := err.Namespace()
+	if i := strings.Index(ns, "."); i >= 0 {
+		return ns[i+1:]
+	}
+
+	return ns
+}
+
+// Error returns the validation failures joined in a single message.
+func (e *ValidationError) Error() string {
+	errorMessages := make([]string, 0, len(e.errs))
+	for _, err := range e.errs {
+		errorMessages = append(errorMessages, err.Error())
+	}
+
+	return fmt.Sprintf("errors: %s", strings.Join(errorMessages, ", "))
+}
+
+// Errors returns the validation failure of every field.
+func (e *ValidationError) Errors() []FieldError {
+	return e.errs
+}
+
+// Unwrap returns the validator.ValidationErrors the error was created from.
+func (e *ValidationError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,181 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-playground/validator"
+)
+
+func TestValidationError(t *testing.T) {
+	type config struct {
+		Name     string `env:"name" validate:"required"`
+		Database struct {
+			Host string `env:"host" validate:"required"`
+			Port int    `env:"port" validate:"min=1"`
+		} `env:"database"`
+	}
+
+	var cfg config
+	err := newYAML(t, "database:\n  port: 0").Unmarshal(&cfg)
+
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Unmarshal() error = %v, want a *ValidationError", err)
+	}
+
+	want := []FieldError{
+		{Field: "name", Tag: "required", Value: ""},
+		{Field: "database.host", Tag: "required", Value: ""},
+		{Field: "database.port", Tag: "min", Param: "1", Value: 0},
+	}
+	got := validationErr.Errors()
+	if len(got) != len(want) {
+		t.Fatalf("Errors() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i].Field != want[i].Field || got[i].Tag != want[i].Tag || got[i].Param != want[i].Param || got[i].Value != want[i].Value {
+			t.Errorf("Errors()[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	wantMsg := "errors: validation error: field 'name' is required, validation error: field 'database.host' is required, validation error: field 'database.port' is min"
+	if err.Error() != wantMsg {
+		t.Errorf("Error() = %q, want %q", err.Error(), wantMsg)
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	type config struct {
+		Database struct {
+			Port    int           `env:"port"`
+			Timeout time.Duration `env:"timeout"`
+		} `env:"database"`
+		Servers []struct {
+			Port int `env:"port"`
+		} `env:"servers"`
+	}
+
+	var cfg config
+	err := newYAML(t, "database:\n  port: abc\n  timeout: 5x\nservers:\n  - port: 80\n  - port: http").Unmarshal(&cfg)
+
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) {
+		t.Fatalf("Unmarshal() error = %v, want a *DecodeError", err)
+	}
+
+	want := map[string]interface{}{
+		"database.port":    "abc",
+		"database.timeout": "5x",
+		"servers[1].port":  "http",
+	}
+	got := decodeErr.Errors()
+	if len(got) != len(want) {
+		t.Fatalf("Errors() = %v, want the keys %v", got, want)
+	}
+	for _, keyErr := range got {
+		if value, ok := want[keyErr.Key]; !ok || keyErr.Value != value || keyErr.Err == nil {
+			t.Errorf("KeyError = %+v, want one of the keys %v", keyErr, want)
+		}
+	}
+}
+
+func TestValidationErrorOneOf(t *testing.T) {
+	type config struct {
+		Environment string `env:"environment" validate:"oneof=dev staging prod"`
+	}
+
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr string
+	}{
+		{name: "allowed value", yaml: "environment: staging"},
+		{name: "other value", yaml: "environment: qa", wantErr: "errors: validation error: field 'environment' must be one of [dev staging prod], got 'qa'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg config
+			err := newYAML(t, tt.yaml).Unmarshal(&cfg)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("Unmarshal() error = %v", err)
+			case tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr):
+				t.Fatalf("Unmarshal() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidationTagName(t *testing.T) {
+	type config struct {
+		Database struct {
+			Host string `env:"host" json:"hostname" validate:"required"`
+		} `env:"database" json:"db"`
+	}
+
+	tests := []struct {
+		name string
+		opts []Option
+		want string
+	}{
+		{name: "env tag", want: "field 'database.host' is required"},
+		{name: "json tag", opts: []Option{WithValidationTagName("json")}, want: "field 'db.hostname' is required"},
+		{name: "go field names", opts: []Option{WithValidationTagName("")}, want: "field 'Database.Host' is required"},
+		{name: "custom validator", opts: []Option{WithValidator(validator.New())}, want: "field 'Database.Host' is required"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newYAML(t, "database:\n  port: 5432", tt.opts...).Unmarshal(&config{})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Unmarshal() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithValidatorUnchanged(t *testing.T) {
+	type config struct {
+		Host string `env:"host" validate:"required"`
+	}
+
+	validate := validator.New()
+	New(WithValidator(validate))
+
+	// The tag name function of the Config isn't registered on the validator
+	err := validate.Struct(config{})
+
+	var fieldErrs validator.ValidationErrors
+	if !errors.As(err, &fieldErrs) || fieldErrs[0].Field() != "Host" {
+		t.Errorf("Struct() error = %v, want the error of the field Host", err)
+	}
+}
+
+func TestErrorFormatter(t *testing.T) {
+	type config struct {
+		Name string `env:"name" validate:"required"`
+		Port int    `env:"port" validate:"min=1"`
+	}
+
+	format := func(err validator.FieldError) string {
+		data, _ := json.Marshal(map[string]string{"field": fieldPath(err), "tag": err.Tag()})
+		return string(data)
+	}
+
+	err := newYAML(t, "port: 0", WithErrorFormatter(format)).Unmarshal(&config{})
+
+	want := `errors: {"field":"name","tag":"required"}, {"field":"port","tag":"min"}`
+	if err == nil || err.Error() != want {
+		t.Errorf("Unmarshal() error = %v, want %q", err, want)
+	}
+
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Errors()[1].Tag != "min" {
+		t.Errorf("Unmarshal() error = %v, want a *ValidationError keeping the tags", err)
+	}
+}
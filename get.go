@@ -0,0 +1,105 @@
+package config
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cast"
+)
+
+// Get returns the value of the key, keys use viper's dotted notation for nested
+// values, e.g. `server.host`. The value is read from the same merged settings
+// Unmarshal uses, so global environment variables are propagated to the nested
+// keys if the propagation is enabled.
+func (c *Config) Get(key string) interface{} {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return lookup(c.settings(), key)
+}
+
+// IsSet reports whether the key was provided by the config file, an
+// environment variable, a flag or Set. Neither the default values, which are
+// set on the structure after decoding, nor the global values propagated to
+// the nested keys are taken into account.
+func (c *Config) IsSet(key string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.v.IsSet(key)
+}
+
+// GetString returns the value of the key as a string.
+func (c *Config) GetString(key string) string {
+	return cast.ToString(c.Get(key))
+}
+
+// GetInt returns the value of the key as an int.
+func (c *Config) GetInt(key string) int {
+	return cast.ToInt(c.Get(key))
+}
+
+// GetBool returns the value of the key as a bool.
+func (c *Config) GetBool(key string) bool {
+	return cast.ToBool(c.Get(key))
+}
+
+// GetDuration returns the value of the key as a time.Duration.
+func (c *Config) GetDuration(key string) time.Duration {
+	return cast.ToDuration(c.Get(key))
+}
+
+// GetStringSlice returns the value of the key as a slice of strings.
+func (c *Config) GetStringSlice(key string) []string {
+	return cast.ToStringSlice(c.Get(key))
+}
+
+// Settings returns all merged settings exactly as Unmarshal decodes them,
+// including the environment variables overrides and the global propagation.
+// It is meant for debugging.
+func (c *Config) Settings() map[string]interface{} {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.settings()
+}
+
+// AllKeys returns the sorted dotted keys of the leaves of the settings, e.g.
+// `database.host`, as provided by the config file, the environment variables,
+// the flags and Set. The keys only created by propagating the global values
+// into the sections are left out. The environment variables of the struct
+// fields are only known once Unmarshal was called. It is meant for
+// documentation and debugging.
+func (c *Config) AllKeys() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	settings, _ := c.rawSettings()
+	leaves := flattenSettings("", settings, make(map[string]interface{}))
+	keys := make([]string, 0, len(leaves))
+	for key := range leaves {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// lookup returns the value of the dotted key in the settings, or nil if the
+// key doesn't exist.
+func lookup(settings map[string]interface{}, key string) interface{} {
+	var value interface{} = settings
+	for _, k := range strings.Split(strings.ToLower(key), ".") {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+
+		if value, ok = m[k]; !ok {
+			return nil
+		}
+	}
+
+	return value
+}
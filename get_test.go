@@ -0,0 +1,111 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAllKeys(t *testing.T) {
+	yaml := "port: 8080\nserver:\n  host: localhost\ndatabase:\n  host: db\n  port: 5432"
+	want := []string{"database.host", "database.port", "port", "server.host", "server.name"}
+
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{name: "without propagation"},
+		{name: "with propagation", opts: []Option{WithGlobalEnvPropagation(true)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newYAML(t, yaml, tt.opts...)
+			c.Set("server.name", "api")
+
+			// The propagated server.port is left out
+			if got := c.AllKeys(); !reflect.DeepEqual(got, want) {
+				t.Errorf("AllKeys() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestGetters(t *testing.T) {
+	yaml := "server:\n  port: 8080\n  debug: true\n  timeout: 30s\n  hosts:\n    - a\n    - b\n  name: api"
+	c := newYAML(t, yaml)
+
+	if got := c.GetInt("server.port"); got != 8080 {
+		t.Errorf("GetInt() = %d, want 8080", got)
+	}
+	if got := c.GetBool("server.debug"); !got {
+		t.Errorf("GetBool() = %t, want true", got)
+	}
+	if got := c.GetDuration("server.timeout"); got != 30*time.Second {
+		t.Errorf("GetDuration() = %v, want 30s", got)
+	}
+	if got := c.GetStringSlice("server.hosts"); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("GetStringSlice() = %v, want [a b]", got)
+	}
+	if got := c.GetString("server.name"); got != "api" {
+		t.Errorf("GetString() = %q, want %q", got, "api")
+	}
+
+	// The environment variables override the file
+	t.Setenv("SERVER_PORT", "9090")
+	if got := c.GetInt("server.port"); got != 9090 {
+		t.Errorf("GetInt() = %d, want 9090 from SERVER_PORT", got)
+	}
+
+	if got := c.GetInt("server.missing"); got != 0 {
+		t.Errorf("GetInt() = %d, want 0 for a missing key", got)
+	}
+}
+
+func TestSettings(t *testing.T) {
+	t.Setenv("DATABASE_HOST", "env")
+
+	c := newYAML(t, "port: 8080\ndatabase:\n  host: file")
+
+	// The bound variables of the struct fields are only known once decoded
+	var cfg struct {
+		Port     int `env:"port"`
+		Database struct {
+			Host string `env:"host"`
+		} `env:"database"`
+	}
+	if err := c.Unmarshal(&cfg); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	settings := c.Settings()
+	if got := settings["port"]; got != 8080 {
+		t.Errorf("Settings()[port] = %v, want 8080 from the file", got)
+	}
+	database, _ := settings["database"].(map[string]interface{})
+	if got := database["host"]; got != "env" {
+		t.Errorf("Settings()[database.host] = %v, want %q from the environment", got, "env")
+	}
+}
+
+func TestIsSet(t *testing.T) {
+	t.Setenv("LOG_LEVEL", "debug")
+
+	c := newYAML(t, "port: 8080\ndatabase:\n  host: db")
+	c.Set("name", "app")
+
+	tests := map[string]bool{
+		"port":          true,
+		"database.host": true,
+		"log_level":     true,
+		"name":          true,
+		"timeout":       false,
+		"database.port": false,
+	}
+
+	for key, want := range tests {
+		if got := c.IsSet(key); got != want {
+			t.Errorf("IsSet(%q) = %t, want %t", key, got, want)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// configType returns the file type the configuration is parsed with, the
+// type of the config file or of the file system file is inferred from the
+// extension unless it is set.
+func (c *Config) configType() string {
+	switch {
+	case c.configFile != "" && c.configFileType != "":
+		return c.configFileType
+	case c.configFile != "":
+		return strings.TrimPrefix(filepath.Ext(c.configFile), ".")
+	case c.fsys != nil:
+		return strings.TrimPrefix(path.Ext(c.fsFileName), ".")
+	}
+
+	return c.fileType
+}
+
+// readHCLConfig reads the HCL configuration into a separate viper instance and
+// merges its settings, with the blocks collapsed, into the configuration. So
+// the blocks are sections for viper, e.g. their keys are read from the
+// environment variables.
+func (c *Config) readHCLConfig() error {
+	v := c.v
+	c.v = viper.New()
+	err := c.readConfig()
+	settings := c.v.AllSettings()
+	c.v = v
+	if err != nil {
+		return err
+	}
+
+	collapseHCLBlocks(settings)
+
+	return c.v.MergeConfigMap(settings)
+}
+
+// collapseHCLBlocks replaces the blocks of the HCL settings, decoded as lists
+// holding a single map, with the map itself, walking the nested blocks. So the
+// `database { host = "db" }` block is decoded into a struct like the yaml
+// sections, the single block lists are still decoded into slice fields.
+func collapseHCLBlocks(settings map[string]interface{}) {
+	for key, value := range settings {
+		if blocks, ok := value.([]map[string]interface{}); ok && len(blocks) == 1 {
+			value = blocks[0]
+			settings[key] = value
+		}
+
+		if section, ok := value.(map[string]interface{}); ok {
+			collapseHCLBlocks(section)
+		}
+	}
+}
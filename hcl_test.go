@@ -0,0 +1,76 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHCL(t *testing.T) {
+	type database struct {
+		Host string `env:"host"`
+		Port int    `env:"port"`
+	}
+	type config struct {
+		Name     string   `env:"name"`
+		Database database `env:"database"`
+	}
+
+	hcl := "name = \"app\"\n\ndatabase {\n  host = \"db\"\n  port = 5432\n}\n"
+
+	tests := []struct {
+		name string
+		file string
+		opts func(dir string) []Option
+		env  map[string]string
+		want config
+	}{
+		{
+			name: "file type",
+			file: "config.hcl",
+			opts: func(dir string) []Option {
+				return []Option{WithFilePath(dir), WithFileName("config"), WithFileType("hcl")}
+			},
+			want: config{Name: "app", Database: database{Host: "db", Port: 5432}},
+		},
+		{
+			name: "config file",
+			file: "app.hcl",
+			opts: func(dir string) []Option { return []Option{WithConfigFile(filepath.Join(dir, "app.hcl"))} },
+			want: config{Name: "app", Database: database{Host: "db", Port: 5432}},
+		},
+		{
+			name: "nested block key from the environment",
+			file: "app.hcl",
+			opts: func(dir string) []Option { return []Option{WithConfigFile(filepath.Join(dir, "app.hcl"))} },
+			env:  map[string]string{"DATABASE_HOST": "env"},
+			want: config{Name: "app", Database: database{Host: "env", Port: 5432}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, tt.file), []byte(hcl), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			c := New(tt.opts(dir)...)
+			if err := c.Err(); err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			var cfg config
+			if err := c.Unmarshal(&cfg); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if cfg != tt.want {
+				t.Errorf("config = %+v, want %+v", cfg, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,375 @@
+package config
+
+import (
+	"encoding"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"math/big"
+	"net"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// typeDecodeHook returns the decode hooks that convert the settings values into
+// the field types, e.g. `30s` into a time.Duration, followed by the hooks set
+// with WithDecodeHook.
+func (c *Config) typeDecodeHook() mapstructure.DecodeHookFunc {
+	hooks := []mapstructure.DecodeHookFunc{
+		mapstructure.StringToTimeDurationHookFunc(),
+		stringToTimeHookFunc(c.timeLayout),
+		stringToLocationHookFunc(),
+		stringToURLHookFunc(),
+		stringToRegexpHookFunc(),
+		stringToIPHookFunc(),
+		stringToIPNetHookFunc(),
+		stringToBoolHookFunc(c.truthyStrings, c.falsyStrings),
+		stringToBytesHookFunc(c.base64Bytes),
+		stringToBigHookFunc(),
+		c.registeredTypeHookFunc(),
+		// Keep it after the hooks of specific types so they take precedence
+		mapstructure.TextUnmarshallerHookFunc(),
+		// Keep it after the hooks of specific slice types such as net.IP
+		mapstructure.StringToSliceHookFunc(c.sliceSeparator),
+	}
+
+	hooks = append(hooks, c.decodeHooks...)
+
+	// Keep it last so the nil pointers returned by any hook are set as nil
+	return mapstructure.ComposeDecodeHookFunc(append(hooks, nilPointerHookFunc())...)
+}
+
+// nilPointerHookFunc converts the nil pointers returned by the previous hooks
+// into an untyped nil, since mapstructure only leaves the pointer fields nil
+// for an untyped nil and allocates them for a typed one. The hooks can't return
+// an untyped nil themselves, the next hooks would fail on its missing type.
+func nilPointerHookFunc() mapstructure.DecodeHookFunc {
+	return func(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+		if f.Kind() != reflect.Ptr || t.Kind() != reflect.Ptr {
+			return data, nil
+		}
+
+		if v := reflect.ValueOf(data); v.IsNil() {
+			return nil, nil
+		}
+
+		return data, nil
+	}
+}
+
+// textUnmarshalerType is the type of the encoding.TextUnmarshaler interface.
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// isValueType reports whether the struct type is decoded from a single value by
+// the decode hooks, e.g. time.Time, instead of from a section.
+func isValueType(t reflect.Type) bool {
+	switch t {
+	case reflect.TypeOf(time.Time{}), reflect.TypeOf(time.Location{}), reflect.TypeOf(url.URL{}), reflect.TypeOf(net.IPNet{}):
+		return true
+	}
+
+	return reflect.PtrTo(t).Implements(textUnmarshalerType)
+}
+
+// stringToTimeHookFunc parses strings into time.Time using the layout, empty
+// strings are decoded as the zero time.
+func stringToTimeHookFunc(layout string) mapstructure.DecodeHookFunc {
+	return func(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+		if f.Kind() != reflect.String || t != reflect.TypeOf(time.Time{}) {
+			return data, nil
+		}
+
+		s := data.(string)
+		if s == "" {
+			return time.Time{}, nil
+		}
+
+		v, err := time.Parse(layout, s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid time %q, expected layout %q", s, layout)
+		}
+
+		return v, nil
+	}
+}
+
+// stringToLocationHookFunc loads the time zones named by the strings into
+// time.Location and *time.Location, e.g. `America/New_York`, empty strings are
+// decoded as the zero location and a nil *time.Location respectively. The
+// location itself is the target when the pointer is already set, e.g. in the
+// second decoding pass.
+func stringToLocationHookFunc() mapstructure.DecodeHookFunc {
+	return func(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+		if f.Kind() != reflect.String || (t != reflect.TypeOf(time.Location{}) && t != reflect.TypeOf(&time.Location{})) {
+			return data, nil
+		}
+
+		s := data.(string)
+		if s == "" {
+			if t.Kind() == reflect.Ptr {
+				return (*time.Location)(nil), nil
+			}
+
+			return time.Location{}, nil
+		}
+
+		loc, err := time.LoadLocation(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid time zone %q", s)
+		}
+
+		return loc, nil
+	}
+}
+
+// stringToURLHookFunc parses strings into url.URL and *url.URL, empty strings
+// are decoded as the zero URL and a nil *url.URL respectively.
+func stringToURLHookFunc() mapstructure.DecodeHookFunc {
+	return func(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+		if f.Kind() != reflect.String || (t != reflect.TypeOf(url.URL{}) && t != reflect.TypeOf(&url.URL{})) {
+			return data, nil
+		}
+
+		s := data.(string)
+		if s == "" {
+			if t.Kind() == reflect.Ptr {
+				return (*url.URL)(nil), nil
+			}
+
+			return url.URL{}, nil
+		}
+
+		u, err := url.Parse(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid URL %q: %w", s, err)
+		}
+
+		return u, nil
+	}
+}
+
+// stringToRegexpHookFunc compiles strings into regexp.Regexp and
+// *regexp.Regexp, empty strings are decoded as a nil *regexp.Regexp and as the
+// empty pattern, matching any string, for the regexp.Regexp values.
+func stringToRegexpHookFunc() mapstructure.DecodeHookFunc {
+	return func(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+		if f.Kind() != reflect.String || (t != reflect.TypeOf(regexp.Regexp{}) && t != reflect.TypeOf(&regexp.Regexp{})) {
+			return data, nil
+		}
+
+		s := data.(string)
+		if s == "" && t.Kind() == reflect.Ptr {
+			return (*regexp.Regexp)(nil), nil
+		}
+
+		re, err := regexp.Compile(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression %q: %w", s, err)
+		}
+
+		return re, nil
+	}
+}
+
+// stringToIPHookFunc parses strings into net.IP, empty strings are decoded as a
+// nil IP.
+func stringToIPHookFunc() mapstructure.DecodeHookFunc {
+	return func(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+		if f.Kind() != reflect.String || t != reflect.TypeOf(net.IP{}) {
+			return data, nil
+		}
+
+		s := data.(string)
+		if s == "" {
+			return net.IP(nil), nil
+		}
+
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP address %q", s)
+		}
+
+		return ip, nil
+	}
+}
+
+// stringToIPNetHookFunc parses CIDR strings into net.IPNet and *net.IPNet, empty
+// strings are decoded as the zero network and a nil *net.IPNet respectively.
+func stringToIPNetHookFunc() mapstructure.DecodeHookFunc {
+	return func(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+		if f.Kind() != reflect.String || (t != reflect.TypeOf(net.IPNet{}) && t != reflect.TypeOf(&net.IPNet{})) {
+			return data, nil
+		}
+
+		s := data.(string)
+		if s == "" {
+			if t.Kind() == reflect.Ptr {
+				return (*net.IPNet)(nil), nil
+			}
+
+			return net.IPNet{}, nil
+		}
+
+		_, ipNet, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q", s)
+		}
+
+		return ipNet, nil
+	}
+}
+
+// stringToBoolHookFunc parses the truthy and falsy strings into bool, ignoring
+// the case, empty strings are decoded as false. If no strings are set the
+// values are left to the decoder, which only understands Go's bool forms.
+func stringToBoolHookFunc(truthy, falsy []string) mapstructure.DecodeHookFunc {
+	return func(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+		if f.Kind() != reflect.String || t.Kind() != reflect.Bool || (len(truthy) == 0 && len(falsy) == 0) {
+			return data, nil
+		}
+
+		s := data.(string)
+		switch {
+		case s == "":
+			return false, nil
+		case containsFold(truthy, s):
+			return true, nil
+		case containsFold(falsy, s):
+			return false, nil
+		}
+
+		return nil, fmt.Errorf("invalid bool %q, expected one of %s", s, strings.Join(append(append([]string(nil), truthy...), falsy...), ", "))
+	}
+}
+
+// containsFold reports whether the values contain s, ignoring the case.
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// stringToBytesHookFunc converts strings into []byte, decoding them from
+// base64 if isBase64 is set. Otherwise the bytes of the string are used as
+// they are instead of splitting it like the other slices.
+func stringToBytesHookFunc(isBase64 bool) mapstructure.DecodeHookFunc {
+	return func(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+		if f.Kind() != reflect.String || t != reflect.TypeOf([]byte(nil)) {
+			return data, nil
+		}
+
+		s := data.(string)
+		if !isBase64 {
+			return []byte(s), nil
+		}
+
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 value: %w", err)
+		}
+
+		return b, nil
+	}
+}
+
+// stringToBigHookFunc parses base 10 strings and numbers into big.Int,
+// *big.Int, big.Float and *big.Float, for the numbers exceeding the int64 and
+// float64 ranges. Empty strings are decoded as a nil pointer and as zero for
+// the values, a float with a fractional part fails the decoding into an
+// integer.
+func stringToBigHookFunc() mapstructure.DecodeHookFunc {
+	return func(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+		switch t {
+		case reflect.TypeOf(big.Int{}), reflect.TypeOf(&big.Int{}):
+			return toBigInt(f, t, data)
+		case reflect.TypeOf(big.Float{}), reflect.TypeOf(&big.Float{}):
+			return toBigFloat(f, t, data)
+		}
+
+		return data, nil
+	}
+}
+
+// toBigInt converts the string or the number into a *big.Int.
+func toBigInt(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+	v := reflect.ValueOf(data)
+	switch f.Kind() {
+	case reflect.String:
+		s := v.String()
+		if s == "" {
+			if t.Kind() == reflect.Ptr {
+				return (*big.Int)(nil), nil
+			}
+
+			return big.Int{}, nil
+		}
+
+		i, ok := new(big.Int).SetString(s, 10)
+		if !ok {
+			return nil, fmt.Errorf("invalid integer %q", s)
+		}
+
+		return i, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return new(big.Int).SetInt64(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return new(big.Int).SetUint64(v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		if math.IsNaN(v.Float()) || math.IsInf(v.Float(), 0) {
+			return nil, fmt.Errorf("invalid integer %v", data)
+		}
+
+		i, accuracy := big.NewFloat(v.Float()).Int(nil)
+		if accuracy != big.Exact {
+			return nil, fmt.Errorf("invalid integer %v", data)
+		}
+
+		return i, nil
+	}
+
+	return data, nil
+}
+
+// toBigFloat converts the string or the number into a *big.Float.
+func toBigFloat(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+	v := reflect.ValueOf(data)
+	switch f.Kind() {
+	case reflect.String:
+		s := v.String()
+		if s == "" {
+			if t.Kind() == reflect.Ptr {
+				return (*big.Float)(nil), nil
+			}
+
+			return big.Float{}, nil
+		}
+
+		fl, ok := new(big.Float).SetString(s)
+		if !ok {
+			return nil, fmt.Errorf("invalid float %q", s)
+		}
+
+		return fl, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return new(big.Float).SetInt64(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return new(big.Float).SetUint64(v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		if math.IsNaN(v.Float()) {
+			return nil, fmt.Errorf("invalid float %v", data)
+		}
+
+		return big.NewFloat(v.Float()), nil
+	}
+
+	return data, nil
+}
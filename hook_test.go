@@ -0,0 +1,433 @@
+package config
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDurationHook(t *testing.T) {
+	type config struct {
+		Timeout time.Duration `env:"timeout"`
+		Retry   time.Duration `env:"retry"`
+	}
+
+	t.Setenv("RETRY", "1m30s")
+
+	var cfg config
+	if err := newYAML(t, "timeout: 30s").Unmarshal(&cfg); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	if cfg.Timeout != 30*time.Second || cfg.Retry != 90*time.Second {
+		t.Errorf("config = %+v, want timeout 30s and retry 1m30s", cfg)
+	}
+}
+
+func TestTimeHook(t *testing.T) {
+	type config struct {
+		StartedAt time.Time `env:"started_at"`
+	}
+
+	tests := []struct {
+		name    string
+		yaml    string
+		opts    []Option
+		want    time.Time
+		wantErr string
+	}{
+		{name: "RFC3339", yaml: "started_at: 2024-01-15T10:00:00Z", want: time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)},
+		{name: "custom layout", yaml: "started_at: 2024-01-15", opts: []Option{WithTimeLayout("2006-01-02")}, want: time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)},
+		{name: "empty string", yaml: `started_at: ""`},
+		{name: "invalid", yaml: "started_at: yesterday", wantErr: "started_at"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg config
+			err := newYAML(t, tt.yaml, tt.opts...).Unmarshal(&cfg)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("Unmarshal() error = %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Fatalf("Unmarshal() error = %v, want an error naming %q", err, tt.wantErr)
+			case tt.wantErr == "" && !cfg.StartedAt.Equal(tt.want):
+				t.Errorf("StartedAt = %v, want %v", cfg.StartedAt, tt.want)
+			}
+		})
+	}
+}
+
+// logLevel is a TextUnmarshaler decoding the level names.
+type logLevel int
+
+// UnmarshalText decodes the level name.
+func (l *logLevel) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "debug":
+		*l = -1
+	case "info":
+		*l = 0
+	default:
+		return fmt.Errorf("unknown log level %q", text)
+	}
+
+	return nil
+}
+
+func TestTextUnmarshalerHook(t *testing.T) {
+	type config struct {
+		Level      logLevel `env:"level"`
+		AuditLevel logLevel `env:"audit_level"`
+	}
+
+	t.Setenv("AUDIT_LEVEL", "debug")
+
+	var cfg config
+	if err := newYAML(t, "level: debug").Unmarshal(&cfg); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if cfg.Level != -1 || cfg.AuditLevel != -1 {
+		t.Errorf("config = %+v, want the debug level from the file and the environment", cfg)
+	}
+
+	if err := newYAML(t, "level: verbose").Unmarshal(&cfg); err == nil || !strings.Contains(err.Error(), "unknown log level") {
+		t.Errorf("Unmarshal() error = %v, want the UnmarshalText error", err)
+	}
+}
+
+func TestDecodeHook(t *testing.T) {
+	type config struct {
+		Name    string        `env:"name"`
+		Timeout time.Duration `env:"timeout"`
+	}
+
+	upper := func(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+		if f.Kind() != reflect.String || t.Kind() != reflect.String {
+			return data, nil
+		}
+
+		return strings.ToUpper(data.(string)), nil
+	}
+
+	var cfg config
+	if err := newYAML(t, "name: app\ntimeout: 5s", WithDecodeHook(upper)).Unmarshal(&cfg); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	// The built-in hooks still run
+	if cfg.Name != "APP" || cfg.Timeout != 5*time.Second {
+		t.Errorf("config = %+v, want name APP and timeout 5s", cfg)
+	}
+}
+
+func TestBoolHook(t *testing.T) {
+	type config struct {
+		Enabled bool `env:"enabled"`
+	}
+
+	opt := WithBoolStrings([]string{"yes", "on", "1"}, []string{"no", "off", "0"})
+
+	tests := []struct {
+		name    string
+		env     string
+		want    bool
+		wantErr bool
+	}{
+		{name: "yes", env: "yes", want: true},
+		{name: "ON", env: "ON", want: true},
+		{name: "off", env: "off", want: false},
+		{name: "invalid", env: "maybe", wantErr: true},
+		{name: "go form not listed", env: "true", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("ENABLED", tt.env)
+
+			var cfg config
+			err := newYAML(t, "enabled: no", opt).Unmarshal(&cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unmarshal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr && !strings.Contains(err.Error(), "invalid bool") {
+				t.Errorf("Unmarshal() error = %v, want the invalid bool error", err)
+			}
+			if !tt.wantErr && cfg.Enabled != tt.want {
+				t.Errorf("Enabled = %t, want %t", cfg.Enabled, tt.want)
+			}
+		})
+	}
+}
+
+func TestLocationHook(t *testing.T) {
+	type config struct {
+		Location *time.Location `env:"location"`
+	}
+
+	tests := []struct {
+		name    string
+		yaml    string
+		want    string
+		wantErr bool
+	}{
+		{name: "named zone", yaml: "location: America/New_York", want: "America/New_York"},
+		{name: "empty string", yaml: `location: ""`},
+		{name: "invalid zone", yaml: "location: Nowhere/City", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg config
+			err := newYAML(t, tt.yaml).Unmarshal(&cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unmarshal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			switch {
+			case tt.want == "" && cfg.Location != nil:
+				t.Errorf("Location = %v, want nil", cfg.Location)
+			case tt.want != "" && (cfg.Location == nil || cfg.Location.String() != tt.want):
+				t.Errorf("Location = %v, want %s", cfg.Location, tt.want)
+			}
+		})
+	}
+}
+
+func TestURLHook(t *testing.T) {
+	type config struct {
+		URL    url.URL  `env:"url"`
+		URLPtr *url.URL `env:"url_ptr"`
+	}
+
+	tests := []struct {
+		name    string
+		yaml    string
+		want    string
+		wantNil bool
+		wantErr bool
+	}{
+		{name: "url", yaml: "url: postgres://db:5432/app\nurl_ptr: postgres://db:5432/app", want: "postgres://db:5432/app"},
+		{name: "empty string", yaml: "url: \"\"\nurl_ptr: \"\"", wantNil: true},
+		{name: "invalid url", yaml: "url_ptr: \"http://[::1\"", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg config
+			err := newYAML(t, tt.yaml).Unmarshal(&cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unmarshal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if got := cfg.URL.String(); got != tt.want {
+				t.Errorf("URL = %q, want %q", got, tt.want)
+			}
+
+			switch {
+			case tt.wantNil && cfg.URLPtr != nil:
+				t.Errorf("URLPtr = %v, want nil", cfg.URLPtr)
+			case !tt.wantNil && (cfg.URLPtr == nil || cfg.URLPtr.String() != tt.want):
+				t.Errorf("URLPtr = %v, want %s", cfg.URLPtr, tt.want)
+			}
+		})
+	}
+}
+
+func TestIPHooks(t *testing.T) {
+	type config struct {
+		IP      net.IP     `env:"ip"`
+		Network net.IPNet  `env:"network"`
+		NetPtr  *net.IPNet `env:"net_ptr"`
+	}
+
+	tests := []struct {
+		name    string
+		yaml    string
+		want    config
+		wantErr bool
+	}{
+		{
+			name: "addresses",
+			yaml: "ip: 10.0.0.1\nnetwork: 10.0.0.0/8\nnet_ptr: 192.168.0.0/16",
+			want: config{
+				IP:      net.ParseIP("10.0.0.1"),
+				Network: net.IPNet{IP: net.IPv4(10, 0, 0, 0).To4(), Mask: net.CIDRMask(8, 32)},
+				NetPtr:  &net.IPNet{IP: net.IPv4(192, 168, 0, 0).To4(), Mask: net.CIDRMask(16, 32)},
+			},
+		},
+		{name: "empty strings", yaml: "ip: \"\"\nnetwork: \"\"\nnet_ptr: \"\""},
+		{name: "invalid ip", yaml: "ip: 10.0.0", wantErr: true},
+		{name: "invalid cidr", yaml: "net_ptr: 10.0.0.0", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg config
+			err := newYAML(t, tt.yaml).Unmarshal(&cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unmarshal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if !cfg.IP.Equal(tt.want.IP) || (cfg.IP == nil) != (tt.want.IP == nil) {
+				t.Errorf("IP = %v, want %v", cfg.IP, tt.want.IP)
+			}
+			if cfg.Network.String() != tt.want.Network.String() {
+				t.Errorf("Network = %v, want %v", cfg.Network.String(), tt.want.Network.String())
+			}
+			if (cfg.NetPtr == nil) != (tt.want.NetPtr == nil) || (cfg.NetPtr != nil && cfg.NetPtr.String() != tt.want.NetPtr.String()) {
+				t.Errorf("NetPtr = %v, want %v", cfg.NetPtr, tt.want.NetPtr)
+			}
+		})
+	}
+}
+
+func TestRegexpHook(t *testing.T) {
+	type config struct {
+		Pattern    regexp.Regexp  `env:"pattern"`
+		PatternPtr *regexp.Regexp `env:"pattern_ptr"`
+	}
+
+	tests := []struct {
+		name    string
+		yaml    string
+		want    string
+		wantNil bool
+		wantErr bool
+	}{
+		{name: "pattern", yaml: "pattern: ^api\\.\npattern_ptr: ^api\\.", want: `^api\.`},
+		{name: "empty string", yaml: "pattern: \"\"\npattern_ptr: \"\"", wantNil: true},
+		{name: "invalid pattern", yaml: "pattern: \"[a-\"", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg config
+			err := newYAML(t, tt.yaml).Unmarshal(&cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unmarshal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if got := cfg.Pattern.String(); got != tt.want {
+				t.Errorf("Pattern = %q, want %q", got, tt.want)
+			}
+
+			switch {
+			case tt.wantNil && cfg.PatternPtr != nil:
+				t.Errorf("PatternPtr = %v, want nil", cfg.PatternPtr)
+			case !tt.wantNil && (cfg.PatternPtr == nil || cfg.PatternPtr.String() != tt.want):
+				t.Errorf("PatternPtr = %v, want %s", cfg.PatternPtr, tt.want)
+			}
+		})
+	}
+}
+
+func TestBigHook(t *testing.T) {
+	type config struct {
+		Int      *big.Int   `env:"int"`
+		IntValue big.Int    `env:"int_value"`
+		Float    *big.Float `env:"float"`
+	}
+
+	tests := []struct {
+		name      string
+		yaml      string
+		wantInt   string
+		wantValue string
+		wantFloat string
+		wantErr   bool
+	}{
+		{name: "large strings", yaml: "int: \"123456789012345678901234567890\"\nint_value: \"-98765432109876543210\"\nfloat: \"1.5e400\"", wantInt: "123456789012345678901234567890", wantValue: "-98765432109876543210", wantFloat: "1.5e+400"},
+		{name: "plain numbers", yaml: "int: 123\nint_value: 7\nfloat: 1.5", wantInt: "123", wantValue: "7", wantFloat: "1.5"},
+		{name: "integral float", yaml: "int: 1e3\nfloat: 2", wantInt: "1000", wantValue: "0", wantFloat: "2"},
+		{name: "empty strings", yaml: "int: \"\"\nint_value: \"\"\nfloat: \"\"", wantValue: "0"},
+		{name: "invalid integer", yaml: "int: 12ab", wantErr: true},
+		{name: "fractional integer", yaml: "int: 1.5", wantErr: true},
+		{name: "invalid float", yaml: "float: 1.5x", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg config
+			err := newYAML(t, tt.yaml).Unmarshal(&cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unmarshal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			switch {
+			case tt.wantInt == "" && cfg.Int != nil:
+				t.Errorf("Int = %v, want nil", cfg.Int)
+			case tt.wantInt != "" && (cfg.Int == nil || cfg.Int.String() != tt.wantInt):
+				t.Errorf("Int = %v, want %s", cfg.Int, tt.wantInt)
+			}
+
+			if got := cfg.IntValue.String(); got != tt.wantValue {
+				t.Errorf("IntValue = %s, want %s", got, tt.wantValue)
+			}
+
+			switch {
+			case tt.wantFloat == "" && cfg.Float != nil:
+				t.Errorf("Float = %v, want nil", cfg.Float)
+			case tt.wantFloat != "" && (cfg.Float == nil || cfg.Float.Text('g', -1) != tt.wantFloat):
+				t.Errorf("Float = %v, want %s", cfg.Float, tt.wantFloat)
+			}
+		})
+	}
+}
+
+func TestBytesHook(t *testing.T) {
+	type config struct {
+		Secret []byte `env:"secret"`
+	}
+
+	tests := []struct {
+		name    string
+		env     string
+		opts    []Option
+		want    string
+		wantErr bool
+	}{
+		{name: "raw bytes", env: "c2VjcmV0", want: "c2VjcmV0"},
+		{name: "base64", env: "c2VjcmV0", opts: []Option{WithBase64Bytes()}, want: "secret"},
+		{name: "invalid base64", env: "not base64!", opts: []Option{WithBase64Bytes()}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SECRET", tt.env)
+
+			var cfg config
+			err := newYAML(t, "secret: file", tt.opts...).Unmarshal(&cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unmarshal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr && !strings.Contains(err.Error(), "invalid base64 value") {
+				t.Errorf("Unmarshal() error = %v, want the invalid base64 error", err)
+			}
+			if !tt.wantErr && string(cfg.Secret) != tt.want {
+				t.Errorf("Secret = %q, want %q", cfg.Secret, tt.want)
+			}
+		})
+	}
+}
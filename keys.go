@@ -0,0 +1,198 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// checkDuplicateKeys returns an error listing the keys defined more than once
+// in the yaml data of the file, ignoring the case like viper does. Viper only
+// rejects the exact duplicates, the keys differing in case are silently
+// collapsed into one. Other file types are not checked. It also records the
+// case of the keys for the key naming strategy.
+func (c *Config) checkDuplicateKeys(file string, data []byte, fileType string) error {
+	c.recordKeyCases(data, fileType)
+
+	if !c.duplicateKeyDetection || (fileType != "yaml" && fileType != "yml") {
+		return nil
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+
+	var duplicates []string
+	for _, node := range doc.Content {
+		duplicates = append(duplicates, duplicateKeys(node, "")...)
+	}
+
+	if len(duplicates) > 0 {
+		return fmt.Errorf("duplicate keys in %s: %s", file, strings.Join(duplicates, ", "))
+	}
+
+	return nil
+}
+
+// checkDuplicateKeysFile reads the config file and checks its duplicate keys,
+// its type is inferred from the extension unless fileType is set. A file that
+// can't be read is left to viper to report.
+func (c *Config) checkDuplicateKeysFile(file, fileType string) error {
+	if (!c.duplicateKeyDetection && c.keyNamingStrategy == nil) || file == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil
+	}
+
+	if fileType == "" {
+		fileType = strings.TrimPrefix(filepath.Ext(file), ".")
+	}
+
+	return c.checkDuplicateKeys(file, data, fileType)
+}
+
+// duplicateKeys returns the keys of the mapping node, and of its nested
+// mappings, defined more than once, e.g. `'port' at lines 1 and 3`.
+func duplicateKeys(node *yaml.Node, prefix string) []string {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	var duplicates []string
+	lines := make(map[string]int)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyNode, valueNode := node.Content[i], node.Content[i+1]
+		if keyNode.Tag == "!!merge" {
+			continue
+		}
+
+		key := prefix + strings.ToLower(keyNode.Value)
+		if line, ok := lines[key]; ok {
+			duplicates = append(duplicates, fmt.Sprintf("'%s' at lines %d and %d", key, line, keyNode.Line))
+		} else {
+			lines[key] = keyNode.Line
+		}
+
+		duplicates = append(duplicates, duplicateKeys(valueNode, key+".")...)
+	}
+
+	return duplicates
+}
+
+// recordKeyCases records the keys of the yaml or json data not written in
+// lowercase by their lowercased dotted path, e.g. `maxConnections` by
+// `database.maxconnections`, as viper lowercases the keys it reads. Nothing is
+// recorded without a key naming strategy.
+func (c *Config) recordKeyCases(data []byte, fileType string) {
+	if c.keyNamingStrategy == nil || (fileType != "yaml" && fileType != "yml" && fileType != "json") {
+		return
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return
+	}
+
+	if c.keyCases == nil {
+		c.keyCases = make(map[string]string)
+	}
+	for _, node := range doc.Content {
+		keyCases(node, "", c.keyCases)
+	}
+}
+
+// keyCases adds the keys of the mapping node, and of its nested mappings, not
+// written in lowercase to cases.
+func keyCases(node *yaml.Node, prefix string, cases map[string]string) {
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyNode, valueNode := node.Content[i], node.Content[i+1]
+		if keyNode.Tag == "!!merge" {
+			continue
+		}
+
+		key := prefix + strings.ToLower(keyNode.Value)
+		if keyNode.Value != strings.ToLower(keyNode.Value) {
+			cases[key] = keyNode.Value
+		}
+		keyCases(valueNode, key+".", cases)
+	}
+}
+
+// KebabToSnake is a key naming strategy converting the kebab-case keys into
+// snake_case, e.g. `max-connections` into `max_connections`.
+func KebabToSnake(key string) string {
+	return strings.ReplaceAll(key, "-", "_")
+}
+
+// CamelToSnake is a key naming strategy converting the camelCase keys into
+// snake_case, e.g. `maxConnections` into `max_connections` and `httpURL` into
+// `http_url`.
+func CamelToSnake(key string) string {
+	runes := []rune(key)
+
+	var b strings.Builder
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+
+	return b.String()
+}
+
+// normalizeKeys returns the settings with the keys of every section converted
+// by the naming strategy, which receives the keys in the case recorded for
+// their dotted path with the prefix, if any. A key already named as the
+// converted one wins over the converted key.
+func normalizeKeys(settings map[string]interface{}, strategy func(string) string, cases map[string]string, prefix string) map[string]interface{} {
+	names := make(map[string]string, len(settings))
+	for key := range settings {
+		name := key
+		if original, ok := cases[prefix+key]; ok {
+			name = original
+		}
+		names[key] = strings.ToLower(strategy(name))
+	}
+
+	normalized := make(map[string]interface{}, len(settings))
+	for key, value := range settings {
+		if section, ok := value.(map[string]interface{}); ok {
+			value = normalizeKeys(section, strategy, cases, prefix+key+".")
+		}
+
+		if names[key] == key {
+			normalized[key] = value
+		}
+	}
+
+	for key, value := range settings {
+		name := names[key]
+		if _, ok := normalized[name]; ok {
+			continue
+		}
+
+		if section, ok := value.(map[string]interface{}); ok {
+			value = normalizeKeys(section, strategy, cases, prefix+key+".")
+		}
+		normalized[name] = value
+	}
+
+	return normalized
+}
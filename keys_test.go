@@ -0,0 +1,122 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestKeyNamingStrategy(t *testing.T) {
+	type database struct {
+		MaxConnections int    `env:"max_connections"`
+		Host           string `env:"host"`
+	}
+	type config struct {
+		Database database `env:"database"`
+	}
+
+	tests := []struct {
+		name     string
+		yaml     string
+		strategy func(string) string
+		want     config
+	}{
+		{
+			name:     "kebab to snake",
+			yaml:     "database:\n  max-connections: 10\n  host: localhost",
+			strategy: KebabToSnake,
+			want:     config{Database: database{MaxConnections: 10, Host: "localhost"}},
+		},
+		{
+			name:     "camel to snake",
+			yaml:     "database:\n  maxConnections: 10\n  host: localhost",
+			strategy: CamelToSnake,
+			want:     config{Database: database{MaxConnections: 10, Host: "localhost"}},
+		},
+		{
+			name:     "converted key loses to the existing one",
+			yaml:     "database:\n  max-connections: 10\n  max_connections: 20",
+			strategy: KebabToSnake,
+			want:     config{Database: database{MaxConnections: 20}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newYAML(t, tt.yaml, WithKeyNamingStrategy(tt.strategy))
+
+			var cfg config
+			if err := c.Unmarshal(&cfg); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+
+			if cfg != tt.want {
+				t.Errorf("config = %+v, want %+v", cfg, tt.want)
+			}
+		})
+	}
+}
+
+func TestCamelToSnake(t *testing.T) {
+	tests := map[string]string{
+		"port":           "port",
+		"maxConnections": "max_connections",
+		"httpURL":        "http_url",
+		"HTTPServer":     "http_server",
+		"tls2Enabled":    "tls2_enabled",
+		"max_conns":      "max_conns",
+	}
+
+	for key, want := range tests {
+		if got := CamelToSnake(key); got != want {
+			t.Errorf("CamelToSnake(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestDuplicateKeyDetection(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		opts    []Option
+		wantErr string
+	}{
+		{
+			name:    "case duplicate",
+			yaml:    "database:\n  port: 5432\n  Port: 5433\n",
+			opts:    []Option{WithDuplicateKeyDetection()},
+			wantErr: "'database.port' at lines 2 and 3",
+		},
+		{
+			name: "no duplicates",
+			yaml: "database:\n  host: db\n  port: 5432\n",
+			opts: []Option{WithDuplicateKeyDetection()},
+		},
+		{
+			name: "detection disabled",
+			yaml: "database:\n  port: 5432\n  Port: 5433\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(tt.yaml), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			err := New(append([]Option{WithFilePath(dir), WithFileName("config")}, tt.opts...)...).Err()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Err() = %v, want nil", err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), "duplicate keys in") || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Err() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,5 +1,16 @@
 package config
 
+import (
+	"io"
+	"io/fs"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator"
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/viper"
+)
+
 // Option represents the option to configure the service.
 type Option func(*Config)
 
@@ -13,13 +24,24 @@ func ApplyOptions(s *Config, opts []Option) {
 // WithFilePath sets the configuration file path.
 func WithFilePath(filePath string) Option {
 	return func(c *Config) {
-		c.filePath = filePath
+		c.sourceOptions = append(c.sourceOptions, "WithFilePath")
+		c.filePaths = []string{filePath}
+	}
+}
+
+// WithFilePaths sets the configuration file paths, they are searched in the
+// given order and the first configuration file found is used.
+func WithFilePaths(filePaths ...string) Option {
+	return func(c *Config) {
+		c.sourceOptions = append(c.sourceOptions, "WithFilePaths")
+		c.filePaths = filePaths
 	}
 }
 
 // WithFileName sets the configuration file name without extension.
 func WithFileName(fileName string) Option {
 	return func(c *Config) {
+		c.sourceOptions = append(c.sourceOptions, "WithFileName")
 		c.fileName = fileName
 	}
 }
@@ -27,6 +49,414 @@ func WithFileName(fileName string) Option {
 // WithFileType sets the configuration file type.
 func WithFileType(fileType string) Option {
 	return func(c *Config) {
+		c.sourceOptions = append(c.sourceOptions, "WithFileType")
+		c.fileType = fileType
+	}
+}
+
+// WithConfigFile sets the full configuration file path, e.g.
+// `/etc/myapp/config.yaml`. The file type is inferred from the extension, so it
+// can't be combined with WithFilePath, WithFileName or WithFileType. Unlike the
+// searched files, a missing file is reported as an error.
+func WithConfigFile(configFile string) Option {
+	return func(c *Config) {
+		c.sourceOptions = append(c.sourceOptions, "WithConfigFile")
+		c.configFile = configFile
+		c.configFileType = ""
+	}
+}
+
+// WithDotEnv sets a dotenv file to read instead of the config file paths, its
+// lines have the `KEY=value` syntax, e.g. `PORT=8080`. Unlike the default
+// `.env` config file, which is read as yaml, the file type is `env`.
+func WithDotEnv(path string) Option {
+	return func(c *Config) {
+		c.sourceOptions = append(c.sourceOptions, "WithDotEnv")
+		c.configFile = path
+		c.configFileType = "env"
+	}
+}
+
+// WithDotEnvFile loads the environment variables of a dotenv file, e.g.
+// `.env.local`, into the process environment before reading the configuration,
+// like godotenv does. The variables already set are not overwritten and a
+// missing file is skipped. Unlike WithDotEnv, the configuration is still read
+// from the config file.
+func WithDotEnvFile(path string) Option {
+	return func(c *Config) {
+		c.dotEnvFiles = append(c.dotEnvFiles, path)
+	}
+}
+
+// WithMergeFiles sets several configuration file names without extension,
+// they are read in the given order and merged, so the later files win on
+// conflicting keys. It takes precedence over WithFileName.
+func WithMergeFiles(fileNames ...string) Option {
+	return func(c *Config) {
+		c.sourceOptions = append(c.sourceOptions, "WithMergeFiles")
+		c.mergeFiles = fileNames
+	}
+}
+
+// WithDefaultsFile sets a config file holding the default values, e.g. a
+// `defaults.yaml` checked into the repository. It is the lowest precedence
+// layer, so the config file and the environment variables override its values.
+// The file type is inferred from the extension and a missing file is reported
+// as an error.
+func WithDefaultsFile(path string) Option {
+	return func(c *Config) {
+		c.defaultsFile = path
+	}
+}
+
+// WithMergeFile merges the config file on top of the configuration, e.g. a
+// toml file holding the secrets over a yaml base file. It can be used several
+// times, the files are merged in order so the later files win on conflicting
+// keys. Every file is read with the parser of fileType, if empty the type is
+// inferred from the extension. A missing file is reported as an error.
+func WithMergeFile(path, fileType string) Option {
+	return func(c *Config) {
+		c.overlayFiles = append(c.overlayFiles, overlayFile{path: path, fileType: fileType})
+	}
+}
+
+// WithRequireFile makes a missing config file an error reported by Err, by
+// default the file is optional. With WithMergeFiles only the first file is
+// required.
+func WithRequireFile() Option {
+	return func(c *Config) {
+		c.requireFile = true
+	}
+}
+
+// WithEnvironmentFile merges an environment specific config file on top of the
+// base one, the environment name is read from the envVar environment variable.
+// E.g. with `APP_ENV=production` the `config.production.yaml` file overrides
+// `config.yaml`. Only the base file is read when envVar is unset.
+func WithEnvironmentFile(envVar string) Option {
+	return func(c *Config) {
+		c.sourceOptions = append(c.sourceOptions, "WithEnvironmentFile")
+		c.environmentVar = envVar
+	}
+}
+
+// WithReader sets a reader to read the configuration from instead of the
+// config file, fileType is the format of its content, e.g. `yaml`.
+func WithReader(r io.Reader, fileType string) Option {
+	return func(c *Config) {
+		c.sourceOptions = append(c.sourceOptions, "WithReader")
+		c.reader = r
 		c.fileType = fileType
 	}
 }
+
+// WithSettings sets a map to read the configuration from instead of the config
+// file, e.g. in tests, the nested maps are the sections. The environment
+// variables still override its values. The map is not modified.
+func WithSettings(settings map[string]interface{}) Option {
+	return func(c *Config) {
+		c.sourceOptions = append(c.sourceOptions, "WithSettings")
+		c.settingsMap = settings
+	}
+}
+
+// WithViper sets a viper instance configured elsewhere, e.g. with its remote
+// providers and aliases, to decode and validate the configuration from instead
+// of creating a new one. The instance is used as it is: no config file is
+// searched and only its own environment settings apply, besides the variables
+// of the struct fields bound by Unmarshal. Reset doesn't read it again, and
+// Clone copies its settings into a new instance.
+func WithViper(v *viper.Viper) Option {
+	return func(c *Config) {
+		c.sourceOptions = append(c.sourceOptions, "WithViper")
+		c.customViper = v
+	}
+}
+
+// WithSourceChain sets the sources to read the configuration from instead of
+// the config file, in priority order: the settings of every source are merged
+// so the earlier sources win on conflicting keys, and the later ones fill the
+// keys they don't set. A source failing is skipped, so the chain falls back on
+// the next ones, it is only an error reported by Err if all of them fail.
+func WithSourceChain(sources ...Source) Option {
+	return func(c *Config) {
+		c.sourceOptions = append(c.sourceOptions, "WithSourceChain")
+		c.sources = sources
+	}
+}
+
+// WithRemoteProvider sets a remote key/value store to read the configuration
+// from instead of the config file, provider is one of viper's supported remote
+// providers, e.g. `consul` or `etcd3`, and path is the key holding the
+// configuration, in the configured file type. The remote support must be
+// enabled with a blank import of `github.com/spf13/viper/remote`.
+func WithRemoteProvider(provider, endpoint, path string) Option {
+	return func(c *Config) {
+		c.sourceOptions = append(c.sourceOptions, "WithRemoteProvider")
+		c.remoteFetcher = remoteProvider{provider: provider, endpoint: endpoint, path: path}
+	}
+}
+
+// WithRemoteFetcher sets the fetcher of a remote key/value store to read the
+// configuration from instead of the config file, like WithRemoteProvider, e.g.
+// for a store viper doesn't support. The content fetched has the configured
+// file type.
+func WithRemoteFetcher(fetcher RemoteFetcher) Option {
+	return func(c *Config) {
+		c.sourceOptions = append(c.sourceOptions, "WithRemoteFetcher")
+		c.remoteFetcher = fetcher
+	}
+}
+
+// WithFS sets a file system to read the name config file from instead of the
+// config file paths, e.g. an embed.FS holding the default configuration. The
+// file type is inferred from the extension.
+func WithFS(fsys fs.FS, name string) Option {
+	return func(c *Config) {
+		c.sourceOptions = append(c.sourceOptions, "WithFS")
+		c.fsys = fsys
+		c.fsFileName = name
+	}
+}
+
+// WithDuplicateKeyDetection makes reading a yaml configuration with keys
+// defined more than once an error listing them, including the keys differing
+// only in case, e.g. `Port` and `port`, which viper silently collapses into one
+// since the keys are case insensitive. The remote configuration is not checked.
+func WithDuplicateKeyDetection() Option {
+	return func(c *Config) {
+		c.duplicateKeyDetection = true
+	}
+}
+
+// WithEnvPrefix sets the prefix environment variables must have to be read,
+// e.g. with the prefix `MYAPP` the `port` key is read from `MYAPP_PORT`.
+func WithEnvPrefix(prefix string) Option {
+	return func(c *Config) {
+		c.envPrefix = prefix
+	}
+}
+
+// WithFileEnv reads the keys from the files named by their environment
+// variable with the `_FILE` suffix, following the Docker and Kubernetes secrets
+// convention, e.g. `password` from the file named by `PASSWORD_FILE`, such as
+// `/run/secrets/db`. The content is trimmed and read on every Unmarshal. The
+// environment variable of the key, e.g. `PASSWORD`, takes precedence, and a
+// file that can't be read fails the decoding.
+func WithFileEnv() Option {
+	return func(c *Config) {
+		c.fileEnv = true
+	}
+}
+
+// WithEnvKeyReplacer sets how the configuration keys are mapped to environment
+// variable names, every occurrence of from is replaced by to. By default the
+// dots are replaced by underscores, so `database.host` is read from
+// `DATABASE_HOST`.
+func WithEnvKeyReplacer(from, to string) Option {
+	return func(c *Config) {
+		c.envKeyReplacer = strings.NewReplacer(from, to)
+	}
+}
+
+// WithTimeLayout sets the layout used to parse time.Time fields, time.RFC3339
+// by default.
+func WithTimeLayout(layout string) Option {
+	return func(c *Config) {
+		c.timeLayout = layout
+	}
+}
+
+// WithSliceSeparator sets the separator used to split strings, e.g. the ones
+// read from environment variables, into slice fields. It is `,` by default.
+func WithSliceSeparator(sep string) Option {
+	return func(c *Config) {
+		c.sliceSeparator = sep
+	}
+}
+
+// WithGlobalEnvPropagation sets whether the top-level values are copied into
+// the nested sections that don't define them, e.g. a top-level `environment`
+// filling `server.environment`. It is disabled by default.
+func WithGlobalEnvPropagation(enabled bool) Option {
+	return func(c *Config) {
+		c.globalEnvPropagation = enabled
+	}
+}
+
+// WithKeyNamingStrategy converts the keys of the settings with fn before
+// matching them with the fields, e.g. KebabToSnake to decode `max-connections`
+// into the field tagged `env:"max_connections"`. Viper lowercases the keys, fn
+// receives the keys of the yaml and json config files in their original case
+// so CamelToSnake can convert them, the other keys are lowercased.
+func WithKeyNamingStrategy(fn func(string) string) Option {
+	return func(c *Config) {
+		c.keyNamingStrategy = fn
+	}
+}
+
+// WithEnvExpansion expands the environment variables referenced by the string
+// values before decoding, e.g. `log_dir: ${HOME}/logs`, with the os.ExpandEnv
+// syntax. The undefined variables are replaced by an empty string.
+func WithEnvExpansion() Option {
+	return func(c *Config) {
+		c.envExpansion = true
+	}
+}
+
+// WithStrictEnvExpansion is like WithEnvExpansion but an undefined variable
+// fails the decoding, naming the variable and the key referencing it.
+func WithStrictEnvExpansion() Option {
+	return func(c *Config) {
+		c.envExpansion = true
+		c.strictEnvExpansion = true
+	}
+}
+
+// WithEmptyAsUnset drops the keys holding an empty string before decoding, so
+// they don't overwrite the default values, even with WithDefaultsFirst, e.g. an
+// empty `host: ""` in the config file. The empty environment variables, e.g.
+// `HOST=`, are always ignored and the config file value is kept.
+func WithEmptyAsUnset() Option {
+	return func(c *Config) {
+		c.emptyAsUnset = true
+	}
+}
+
+// WithSliceEnvExtend extends the slices overridden by the environment
+// variables of an index out of range, e.g. `HOSTS_3` for the three elements of
+// `hosts`, instead of failing. The missing elements are zero.
+func WithSliceEnvExtend() Option {
+	return func(c *Config) {
+		c.sliceEnvExtend = true
+	}
+}
+
+// WithBase64Bytes decodes the []byte fields from base64 strings, e.g. the
+// secrets passed in environment variables. A value that is not valid base64
+// fails the decoding. By default the bytes of the string are used.
+func WithBase64Bytes() Option {
+	return func(c *Config) {
+		c.base64Bytes = true
+	}
+}
+
+// WithBoolStrings sets the strings decoded into the bool fields, e.g. `yes`
+// and `on` as truthy and `no` and `off` as falsy, ignoring the case. Any other
+// value fails the decoding, including Go's bool forms unless they are listed.
+func WithBoolStrings(truthy, falsy []string) Option {
+	return func(c *Config) {
+		c.truthyStrings = truthy
+		c.falsyStrings = falsy
+	}
+}
+
+// WithDecodeHook adds mapstructure decode hooks, they run after the built-in
+// hooks when decoding the settings into the config structures.
+func WithDecodeHook(hooks ...mapstructure.DecodeHookFunc) Option {
+	return func(c *Config) {
+		c.decodeHooks = append(c.decodeHooks, hooks...)
+	}
+}
+
+// WithStrictDecoding makes Unmarshal fail when the settings have keys that
+// don't match any field of the config structure, e.g. a misspelled key in the
+// configuration file.
+func WithStrictDecoding() Option {
+	return func(c *Config) {
+		c.strictDecoding = true
+	}
+}
+
+// WithCollectAllErrors makes Unmarshal return all the errors of the strict
+// check, the decoding, the defaults, the post-processing and the validation
+// joined with errors.Join, instead of stopping at the first one, e.g. for the
+// tools linting the configuration. The validation still runs when the decoding
+// fails, on the fields decoded.
+func WithCollectAllErrors() Option {
+	return func(c *Config) {
+		c.collectAllErrors = true
+	}
+}
+
+// WithTagName sets the struct tag used for field mapping, `env` by default.
+func WithTagName(tagName string) Option {
+	return func(c *Config) {
+		c.tagName = tagName
+	}
+}
+
+// WithPostProcess adds a function transforming the config structures decoded
+// by Unmarshal once the default values are set, before validating them, e.g.
+// to trim or lowercase values. If it returns an error Unmarshal returns it.
+// The functions are run in the order they were added.
+func WithPostProcess(fn func(config interface{}) error) Option {
+	return func(c *Config) {
+		c.postProcess = append(c.postProcess, fn)
+	}
+}
+
+// WithObserver sets a function called with the duration of every loading
+// stage, e.g. to export them as metrics. The stages are `read`, reading the
+// configuration in New and on every reload, and `env-bind`, `decode`,
+// `defaults` and `validate`, run by Unmarshal. Every stage run is reported
+// once, even if it fails, the stages skipped are not reported.
+func WithObserver(fn func(stage string, d time.Duration)) Option {
+	return func(c *Config) {
+		c.observer = fn
+	}
+}
+
+// WithDefaultsFirst sets the default values before decoding instead of after,
+// so a field explicitly set to its zero value keeps it instead of the default.
+func WithDefaultsFirst() Option {
+	return func(c *Config) {
+		c.defaultsFirst = true
+	}
+}
+
+// WithoutDefaults skips setting the default values from the `default` tags,
+// e.g. when the config structures are defaulted elsewhere.
+func WithoutDefaults() Option {
+	return func(c *Config) {
+		c.withoutDefaults = true
+	}
+}
+
+// WithValidator sets the validator used to validate the config structures,
+// e.g. one with custom validations already registered. The `required_if` tag
+// of the default validator is not registered on it, and neither is the
+// WithValidationTagName name function, so the validator isn't changed and the
+// errors name the fields as its own tag name function does.
+func WithValidator(validate *validator.Validate) Option {
+	return func(c *Config) {
+		c.validate = validate
+	}
+}
+
+// WithValidationTagName sets the struct tag naming the fields in the
+// validation errors, `env` by default, so the errors use the names set in the
+// configuration, e.g. `database.host`. An empty tag keeps the Go field names,
+// e.g. `Database.Host`.
+func WithValidationTagName(tag string) Option {
+	return func(c *Config) {
+		c.validationTagName = tag
+	}
+}
+
+// WithErrorFormatter sets the function formatting the failure of every field
+// in the validation errors, by default `validation error: field 'host' is
+// required`.
+func WithErrorFormatter(format func(validator.FieldError) string) Option {
+	return func(c *Config) {
+		c.errorFormatter = format
+	}
+}
+
+// WithRedactKeys sets the names of the sensitive keys redacted by
+// RedactedSettings, a key is sensitive when its name contains any of them.
+func WithRedactKeys(keys ...string) Option {
+	return func(c *Config) {
+		c.redactKeys = keys
+	}
+}
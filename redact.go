@@ -0,0 +1,60 @@
+package config
+
+import "strings"
+
+// redactedValue replaces the values of the sensitive keys.
+const redactedValue = "****"
+
+// defaultRedactKeys are the default names of the sensitive keys.
+var defaultRedactKeys = []string{"password", "secret", "token"}
+
+// RedactedSettings returns the same settings as Settings with the values of
+// the sensitive keys replaced by `****`, so they can be logged. A key is
+// sensitive when its name contains any of the redact keys, which are
+// `password`, `secret` and `token` unless set with WithRedactKeys.
+func (c *Config) RedactedSettings() map[string]interface{} {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.redact(c.settings()).(map[string]interface{})
+}
+
+// redact returns a copy of the value with the values of the sensitive keys
+// replaced, walking the nested maps and slices.
+func (c *Config) redact(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(value))
+		for k, v := range value {
+			if c.isSensitiveKey(k) {
+				redacted[k] = redactedValue
+				continue
+			}
+
+			redacted[k] = c.redact(v)
+		}
+
+		return redacted
+	case []interface{}:
+		redacted := make([]interface{}, len(value))
+		for i, v := range value {
+			redacted[i] = c.redact(v)
+		}
+
+		return redacted
+	default:
+		return value
+	}
+}
+
+// isSensitiveKey reports whether the key name contains any of the redact keys.
+func (c *Config) isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, redactKey := range c.redactKeys {
+		if strings.Contains(key, strings.ToLower(redactKey)) {
+			return true
+		}
+	}
+
+	return false
+}
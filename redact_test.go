@@ -0,0 +1,49 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRedactedSettings(t *testing.T) {
+	yaml := "host: localhost\ndatabase:\n  password: hunter2\n  api_key: abc\nservers:\n  - token: t1\n    port: 80"
+
+	tests := []struct {
+		name string
+		opts []Option
+		want map[string]interface{}
+	}{
+		{
+			name: "default keys",
+			want: map[string]interface{}{
+				"host":     "localhost",
+				"database": map[string]interface{}{"password": "****", "api_key": "abc"},
+				"servers":  []interface{}{map[string]interface{}{"token": "****", "port": 80}},
+			},
+		},
+		{
+			name: "redact keys",
+			opts: []Option{WithRedactKeys("api_key", "host")},
+			want: map[string]interface{}{
+				"host":     "****",
+				"database": map[string]interface{}{"password": "hunter2", "api_key": "****"},
+				"servers":  []interface{}{map[string]interface{}{"token": "t1", "port": 80}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newYAML(t, yaml, tt.opts...)
+
+			if got := c.RedactedSettings(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RedactedSettings() = %v, want %v", got, tt.want)
+			}
+
+			// The settings themselves are left unchanged
+			if got := c.GetString("database.password"); got != "hunter2" {
+				t.Errorf("GetString() = %q, want %q", got, "hunter2")
+			}
+		})
+	}
+}
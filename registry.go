@@ -0,0 +1,95 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/creasty/defaults"
+	"github.com/mitchellh/mapstructure"
+)
+
+// typeKey is the key of the sections naming their registered type.
+const typeKey = "type"
+
+// RegisterType registers the factory of the config type named name, so the
+// sections decoded into an interface field, other than the empty interface,
+// and holding a `type: name` key are decoded into the value returned by
+// factory, e.g. the plugin configurations. The value, usually a pointer to a
+// structure, must implement the interface of the field.
+func (c *Config) RegisterType(name string, factory func() interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Copy the types so the clones sharing them are not changed
+	types := make(map[string]func() interface{}, len(c.types)+1)
+	for n, f := range c.types {
+		types[n] = f
+	}
+	types[strings.ToLower(name)] = factory
+	c.types = types
+}
+
+// registeredTypeHookFunc decodes the sections holding a `type` key into the
+// registered type it names when the target is an interface.
+func (c *Config) registeredTypeHookFunc() mapstructure.DecodeHookFunc {
+	return func(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+		if len(c.types) == 0 || t.Kind() != reflect.Interface || t.NumMethod() == 0 {
+			return data, nil
+		}
+
+		section, ok := data.(map[string]interface{})
+		if !ok {
+			return data, nil
+		}
+
+		name, ok := section[typeKey].(string)
+		if !ok {
+			return data, nil
+		}
+
+		factory, ok := c.types[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown type %q, expected one of %s", name, strings.Join(c.typeNames(), ", "))
+		}
+
+		value := factory()
+		if !reflect.TypeOf(value).Implements(t) {
+			return nil, fmt.Errorf("type %q is a %T, which doesn't implement %s", name, value, t)
+		}
+
+		// Set the default values like Unmarshal does, the ones of the
+		// interface fields are not reached from the config structure
+		rv := reflect.ValueOf(value)
+		setDefaults := !c.withoutDefaults && rv.Kind() == reflect.Ptr && rv.Elem().Kind() == reflect.Struct
+		if setDefaults && c.defaultsFirst {
+			if err := defaults.Set(value); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := c.decodeConfig(section, value); err != nil {
+			return nil, err
+		}
+
+		if setDefaults && !c.defaultsFirst {
+			if err := defaults.Set(value); err != nil {
+				return nil, err
+			}
+		}
+
+		return value, nil
+	}
+}
+
+// typeNames returns the sorted names of the registered types.
+func (c *Config) typeNames() []string {
+	names := make([]string, 0, len(c.types))
+	for name := range c.types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+type storage interface {
+	Kind() string
+}
+
+type s3Storage struct {
+	Bucket string `env:"bucket"`
+	Region string `env:"region" default:"us-east-1"`
+}
+
+func (*s3Storage) Kind() string { return "s3" }
+
+type diskStorage struct {
+	Path string `env:"path"`
+}
+
+func (*diskStorage) Kind() string { return "disk" }
+
+func TestRegisterType(t *testing.T) {
+	type config struct {
+		Primary storage `env:"primary"`
+		Backup  storage `env:"backup"`
+	}
+
+	yaml := "primary:\n  type: s3\n  bucket: data\nbackup:\n  type: disk\n  path: /backup"
+
+	c := newYAML(t, yaml)
+	c.RegisterType("s3", func() interface{} { return &s3Storage{} })
+	c.RegisterType("disk", func() interface{} { return &diskStorage{} })
+
+	var cfg config
+	if err := c.Unmarshal(&cfg); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	primary, ok := cfg.Primary.(*s3Storage)
+	if !ok || primary.Bucket != "data" || primary.Region != "us-east-1" {
+		t.Errorf("Primary = %#v, want the s3 storage with the default region", cfg.Primary)
+	}
+	backup, ok := cfg.Backup.(*diskStorage)
+	if !ok || backup.Path != "/backup" {
+		t.Errorf("Backup = %#v, want the disk storage", cfg.Backup)
+	}
+}
+
+func TestRegisterTypeUnknown(t *testing.T) {
+	type config struct {
+		Primary storage `env:"primary"`
+	}
+
+	c := newYAML(t, "primary:\n  type: gcs")
+	c.RegisterType("s3", func() interface{} { return &s3Storage{} })
+
+	err := c.Unmarshal(&config{})
+	if err == nil || !strings.Contains(err.Error(), `unknown type "gcs", expected one of s3`) {
+		t.Errorf("Unmarshal() error = %v, want the unknown type error", err)
+	}
+}
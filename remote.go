@@ -0,0 +1,62 @@
+package config
+
+import (
+	"io"
+
+	"github.com/spf13/viper"
+)
+
+// RemoteFetcher fetches the configuration stored in a remote key/value store,
+// e.g. a store viper doesn't support, for WithRemoteFetcher.
+type RemoteFetcher interface {
+	// Fetch returns the content of the configuration, in the configured file
+	// type.
+	Fetch() ([]byte, error)
+}
+
+// remoteProvider fetches the configuration from one of viper's supported
+// remote providers.
+type remoteProvider struct {
+	provider string
+	endpoint string
+	path     string
+}
+
+// Provider returns the name of the remote provider, e.g. `consul`.
+func (p remoteProvider) Provider() string {
+	return p.provider
+}
+
+// Endpoint returns the address of the remote key/value store.
+func (p remoteProvider) Endpoint() string {
+	return p.endpoint
+}
+
+// Path returns the key holding the configuration in the remote store.
+func (p remoteProvider) Path() string {
+	return p.path
+}
+
+// SecretKeyring returns no keyring, the configuration is not encrypted.
+func (p remoteProvider) SecretKeyring() string {
+	return ""
+}
+
+// Fetch reads the configuration from the remote store with viper's remote
+// support, enabled with a blank import of `github.com/spf13/viper/remote`.
+func (p remoteProvider) Fetch() ([]byte, error) {
+	if !containsFold(viper.SupportedRemoteProviders, p.provider) {
+		return nil, viper.UnsupportedRemoteProviderError(p.provider)
+	}
+
+	if viper.RemoteConfig == nil {
+		return nil, viper.RemoteConfigError("Enable the remote features by doing a blank import of the viper/remote package: '_ github.com/spf13/viper/remote'")
+	}
+
+	r, err := viper.RemoteConfig.Get(p)
+	if err != nil {
+		return nil, err
+	}
+
+	return io.ReadAll(r)
+}
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeFetcher is a RemoteFetcher returning the data set with set.
+type fakeFetcher struct {
+	mu   sync.Mutex
+	data string
+	err  error
+
+	// fetching, if not nil, is notified when Fetch is called and release
+	// then blocks the fetch until it is closed.
+	fetching chan struct{}
+	release  chan struct{}
+}
+
+// Fetch returns the data or the error set.
+func (f *fakeFetcher) Fetch() ([]byte, error) {
+	if f.fetching != nil {
+		select {
+		case f.fetching <- struct{}{}:
+		default:
+		}
+		<-f.release
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	return []byte(f.data), nil
+}
+
+// set sets the data and the error returned by the next fetches.
+func (f *fakeFetcher) set(data string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.data, f.err = data, err
+}
+
+func TestRemoteFetcher(t *testing.T) {
+	type config struct {
+		Host string `env:"host" validate:"required"`
+		Port int    `env:"port" default:"8080"`
+	}
+
+	tests := []struct {
+		name    string
+		data    string
+		err     error
+		want    config
+		wantErr string
+	}{
+		{name: "remote values", data: "host: remote\nport: 9090", want: config{Host: "remote", Port: 9090}},
+		{name: "default values", data: "host: remote", want: config{Host: "remote", Port: 8080}},
+		{name: "validation", data: "port: 9090", wantErr: "field 'host' is required"},
+		{name: "fetch error", err: errors.New("store unavailable"), wantErr: "store unavailable"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fetcher := &fakeFetcher{}
+			fetcher.set(tt.data, tt.err)
+
+			c := New(WithRemoteFetcher(fetcher), WithFileType("yaml"))
+			err := c.Err()
+
+			var cfg config
+			if err == nil {
+				err = c.Unmarshal(&cfg)
+			}
+
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("error = %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Fatalf("error = %v, want %q", err, tt.wantErr)
+			case tt.wantErr == "" && cfg != tt.want:
+				t.Errorf("config = %+v, want %+v", cfg, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemoteProviderUnsupported(t *testing.T) {
+	c := New(WithRemoteProvider("zookeeper", "localhost:2181", "/myapp/config"))
+	if err := c.Err(); err == nil || !strings.Contains(err.Error(), "zookeeper") {
+		t.Errorf("Err() = %v, want the unsupported provider error", err)
+	}
+}
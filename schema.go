@@ -0,0 +1,190 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// jsonSchemaVersion is the JSON Schema version of the generated schemas.
+const jsonSchemaVersion = "https://json-schema.org/draft/2020-12/schema"
+
+// jsonSchema is the JSON Schema of a value.
+type jsonSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	Type                 string                 `json:"type,omitempty"`
+	Format               string                 `json:"format,omitempty"`
+	Properties           map[string]*jsonSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	Items                *jsonSchema            `json:"items,omitempty"`
+	AdditionalProperties *jsonSchema            `json:"additionalProperties,omitempty"`
+	Minimum              *float64               `json:"minimum,omitempty"`
+	Maximum              *float64               `json:"maximum,omitempty"`
+	MinLength            *float64               `json:"minLength,omitempty"`
+	MaxLength            *float64               `json:"maxLength,omitempty"`
+	MinItems             *float64               `json:"minItems,omitempty"`
+	MaxItems             *float64               `json:"maxItems,omitempty"`
+}
+
+// GenerateSchema returns the JSON Schema of the config structure, e.g. to
+// document or validate the config file with other tools. The properties are
+// named with the lowercased `env` tags, like viper names the keys, and the
+// `required`, `min` and `max` validations are kept.
+func GenerateSchema(config interface{}) ([]byte, error) {
+	t := reflect.TypeOf(config)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("config must be a struct or a pointer to a struct, got %T", config)
+	}
+
+	schema := structSchema(t, nil)
+	schema.Schema = jsonSchemaVersion
+
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+// structSchema returns the schema of the struct type, the types being walked
+// are left unrestricted so the recursive types end.
+func structSchema(t reflect.Type, walking []reflect.Type) *jsonSchema {
+	for _, w := range walking {
+		if w == t {
+			return &jsonSchema{}
+		}
+	}
+	walking = append(walking, t)
+
+	schema := &jsonSchema{Type: "object", Properties: make(map[string]*jsonSchema)}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+
+		// The embedded structs are flattened
+		if field.Anonymous && fieldType.Kind() == reflect.Struct && !isValueType(fieldType) {
+			embedded := structSchema(fieldType, walking)
+			for name, property := range embedded.Properties {
+				schema.Properties[name] = property
+			}
+			schema.Required = append(schema.Required, embedded.Required...)
+			continue
+		}
+
+		name := tagKeyName(field, defaultTagName)
+		if name == "" {
+			continue
+		}
+		name = strings.ToLower(name)
+
+		property := typeSchema(field.Type, walking)
+		if applyValidations(property, field.Tag.Get("validate")) {
+			schema.Required = append(schema.Required, name)
+		}
+		schema.Properties[name] = property
+	}
+
+	return schema
+}
+
+// typeSchema returns the schema of the type, the types decoded from strings by
+// the decode hooks, e.g. time.Duration, are strings.
+func typeSchema(t reflect.Type, walking []reflect.Type) *jsonSchema {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t {
+	case reflect.TypeOf(time.Duration(0)):
+		return &jsonSchema{Type: "string"}
+	case reflect.TypeOf(time.Time{}):
+		return &jsonSchema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &jsonSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &jsonSchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &jsonSchema{Type: "number"}
+	case reflect.String:
+		return &jsonSchema{Type: "string"}
+	case reflect.Struct:
+		if isValueType(t) {
+			return &jsonSchema{Type: "string"}
+		}
+
+		return structSchema(t, walking)
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 || reflect.PtrTo(t).Implements(textUnmarshalerType) {
+			return &jsonSchema{Type: "string"}
+		}
+
+		return &jsonSchema{Type: "array", Items: typeSchema(t.Elem(), walking)}
+	case reflect.Map:
+		return &jsonSchema{Type: "object", AdditionalProperties: typeSchema(t.Elem(), walking)}
+	}
+
+	return &jsonSchema{}
+}
+
+// applyValidations sets the limits of the `min` and `max` validations on the
+// schema and reports whether the field is required. The validations of the
+// elements, after `dive`, are ignored.
+func applyValidations(schema *jsonSchema, tag string) bool {
+	required := false
+	for _, rule := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "dive":
+			return required
+		case "required":
+			required = true
+		case "min", "max":
+			limit, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				continue
+			}
+
+			setLimit(schema, name == "min", limit)
+		}
+	}
+
+	return required
+}
+
+// setLimit sets the minimum or maximum of the schema, the value for numbers,
+// the length for strings and the number of items for arrays.
+func setLimit(schema *jsonSchema, isMin bool, limit float64) {
+	switch schema.Type {
+	case "integer", "number":
+		if isMin {
+			schema.Minimum = &limit
+		} else {
+			schema.Maximum = &limit
+		}
+	case "string":
+		if isMin {
+			schema.MinLength = &limit
+		} else {
+			schema.MaxLength = &limit
+		}
+	case "array":
+		if isMin {
+			schema.MinItems = &limit
+		} else {
+			schema.MaxItems = &limit
+		}
+	}
+}
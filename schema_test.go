@@ -0,0 +1,56 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestGenerateSchema(t *testing.T) {
+	type database struct {
+		Host string `env:"host" validate:"required"`
+		Port int    `env:"port" validate:"min=1,max=65535"`
+	}
+	type config struct {
+		Name     string   `env:"name" validate:"required"`
+		Tags     []string `env:"tags" validate:"required,dive,min=2"`
+		Database database `env:"database"`
+	}
+
+	data, err := GenerateSchema(&config{})
+	if err != nil {
+		t.Fatalf("GenerateSchema() error = %v", err)
+	}
+
+	var schema jsonSchema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+
+	if schema.Schema != jsonSchemaVersion {
+		t.Errorf("$schema = %q, want %q", schema.Schema, jsonSchemaVersion)
+	}
+	if want := []string{"name", "tags"}; !reflect.DeepEqual(schema.Required, want) {
+		t.Errorf("required = %v, want %v", schema.Required, want)
+	}
+
+	db := schema.Properties["database"]
+	if db == nil {
+		t.Fatalf("properties = %v, want database", schema.Properties)
+	}
+	if want := []string{"host"}; !reflect.DeepEqual(db.Required, want) {
+		t.Errorf("database required = %v, want %v", db.Required, want)
+	}
+	if port := db.Properties["port"]; port == nil || port.Minimum == nil || *port.Minimum != 1 || port.Maximum == nil || *port.Maximum != 65535 {
+		t.Errorf("database.port = %+v, want the 1-65535 limits", port)
+	}
+	if tags := schema.Properties["tags"]; tags == nil || tags.MinItems != nil {
+		t.Errorf("tags = %+v, want the dive limit ignored", tags)
+	}
+}
+
+func TestGenerateSchemaNotStruct(t *testing.T) {
+	if _, err := GenerateSchema(42); err == nil {
+		t.Error("GenerateSchema(42) error = nil, want an error")
+	}
+}
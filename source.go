@@ -0,0 +1,40 @@
+package config
+
+// Source is a source of configuration settings for WithSourceChain, e.g. a
+// remote store or a secrets manager.
+type Source interface {
+	// Load returns the settings, the nested maps are the sections.
+	Load() (map[string]interface{}, error)
+}
+
+// SourceFunc is a function used as a Source.
+type SourceFunc func() (map[string]interface{}, error)
+
+// Load calls f.
+func (f SourceFunc) Load() (map[string]interface{}, error) {
+	return f()
+}
+
+// loadSources merges the settings of the source chain, the earlier sources
+// take precedence on conflicting keys. The sources failing are skipped, it is
+// only an error if all of them fail.
+func (c *Config) loadSources() error {
+	var errs []error
+	for i := len(c.sources) - 1; i >= 0; i-- {
+		settings, err := c.sources[i].Load()
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		if err := c.v.MergeConfigMap(copySettings(settings)); err != nil {
+			return err
+		}
+	}
+
+	if len(errs) == len(c.sources) {
+		return joinErrors(errs)
+	}
+
+	return nil
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"errors"
+	"testing"
+)
+
+// fakeSource is a Source returning fixed settings, counting its loads.
+type fakeSource struct {
+	settings map[string]interface{}
+	err      error
+	loads    int
+}
+
+func (s *fakeSource) Load() (map[string]interface{}, error) {
+	s.loads++
+	return s.settings, s.err
+}
+
+func TestSourceChain(t *testing.T) {
+	type config struct {
+		Host     string `env:"host"`
+		Port     int    `env:"port"`
+		Database struct {
+			Host string `env:"host"`
+			Port int    `env:"port"`
+		} `env:"database"`
+	}
+
+	high := &fakeSource{settings: map[string]interface{}{
+		"host":     "high",
+		"database": map[string]interface{}{"host": "high.db"},
+	}}
+	low := SourceFunc(func() (map[string]interface{}, error) {
+		return map[string]interface{}{
+			"host":     "low",
+			"port":     8080,
+			"database": map[string]interface{}{"host": "low.db", "port": 5432},
+		}, nil
+	})
+
+	c := New(WithSourceChain(high, low))
+	if err := c.Err(); err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	var cfg config
+	if err := c.Unmarshal(&cfg); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	if cfg.Host != "high" || cfg.Port != 8080 || cfg.Database.Host != "high.db" || cfg.Database.Port != 5432 {
+		t.Errorf("Unmarshal() = %+v, want the high source keys and the low source filling the others", cfg)
+	}
+	if high.loads != 1 {
+		t.Errorf("loads = %d, want 1", high.loads)
+	}
+}
+
+func TestSourceChainErrors(t *testing.T) {
+	errHigh := errors.New("high unavailable")
+	errLow := errors.New("low unavailable")
+
+	t.Run("fallback", func(t *testing.T) {
+		c := New(WithSourceChain(&fakeSource{err: errHigh}, &fakeSource{settings: map[string]interface{}{"port": 8080}}))
+		if err := c.Err(); err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		if port := c.GetInt("port"); port != 8080 {
+			t.Errorf("GetInt() = %d, want the port of the fallback source", port)
+		}
+	})
+
+	t.Run("all failing", func(t *testing.T) {
+		err := New(WithSourceChain(&fakeSource{err: errHigh}, &fakeSource{err: errLow})).Err()
+		if !errors.Is(err, errHigh) || !errors.Is(err, errLow) {
+			t.Errorf("Err() = %v, want the errors of both sources", err)
+		}
+	})
+}
@@ -0,0 +1,46 @@
+package config
+
+// TypedConfig is a Config decoding the configuration into a structure of type
+// T, so the common single structure use case doesn't need to pass the
+// structure pointer to every call.
+type TypedConfig[T any] struct {
+	config *Config
+}
+
+// NewTyped creates a TypedConfig decoding into T, the options are the ones of
+// New.
+func NewTyped[T any](opts ...Option) *TypedConfig[T] {
+	return &TypedConfig[T]{config: New(opts...)}
+}
+
+// Config returns the wrapped Config, e.g. to read single values or watch the
+// config file.
+func (t *TypedConfig[T]) Config() *Config {
+	return t.config
+}
+
+// Load decodes the configuration into a new T, returning the error reading the
+// config file, if any, or the Unmarshal error.
+func (t *TypedConfig[T]) Load() (*T, error) {
+	if err := t.config.Err(); err != nil {
+		return nil, err
+	}
+
+	config := new(T)
+	if err := t.config.Unmarshal(config); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// Reload reads the configuration again from the environment variables and the
+// config file, keeping the values set with Set, and decodes it into a new T.
+// The current settings are kept if the configuration can't be read.
+func (t *TypedConfig[T]) Reload() (*T, error) {
+	if err := t.config.reread(); err != nil {
+		return nil, err
+	}
+
+	return t.Load()
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type typedApp struct {
+	Name     string `env:"name" validate:"required"`
+	Database struct {
+		Host string `env:"host"`
+		Port int    `env:"port" default:"5432"`
+	} `env:"database"`
+}
+
+func TestTypedConfig(t *testing.T) {
+	app, err := NewTyped[typedApp](WithReader(strings.NewReader("name: app\ndatabase:\n  host: db"), "yaml")).Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if app.Name != "app" || app.Database.Host != "db" || app.Database.Port != 5432 {
+		t.Errorf("Load() = %+v, want the file values and the default port", app)
+	}
+}
+
+func TestTypedConfigErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{name: "read error", opts: []Option{WithConfigFile(filepath.Join(t.TempDir(), "missing.yaml"))}},
+		{name: "validation error", opts: []Option{WithReader(strings.NewReader("database:\n  host: db"), "yaml")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, err := NewTyped[typedApp](tt.opts...).Load()
+			if err == nil || app != nil {
+				t.Errorf("Load() = %+v, %v, want nil and an error", app, err)
+			}
+		})
+	}
+}
+
+func TestTypedConfigReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeFile(t, path, "name: before")
+
+	typed := NewTyped[typedApp](WithConfigFile(path))
+	writeFile(t, path, "name: after")
+
+	app, err := typed.Reload()
+	if err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if app.Name != "after" {
+		t.Errorf("Name = %q, want %q", app.Name, "after")
+	}
+}
@@ -0,0 +1,48 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator"
+)
+
+// newValidator creates the default validator, with the `required_if` tag the
+// validator version used doesn't have.
+func newValidator() *validator.Validate {
+	validate := validator.New()
+	validate.RegisterValidation("required_if", requiredIf, true)
+
+	return validate
+}
+
+// requiredIf validates the field is not zero if the other fields of the same
+// structure have the given values, e.g. `required_if=TLSEnabled true`. Several
+// field and value pairs can be given, the field is required if all of them
+// match.
+func requiredIf(fl validator.FieldLevel) bool {
+	params := strings.Fields(fl.Param())
+	if len(params)%2 != 0 {
+		panic(fmt.Sprintf("bad param number for required_if %s", fl.FieldName()))
+	}
+
+	for i := 0; i < len(params); i += 2 {
+		field, _, _, found := fl.GetStructFieldOKAdvanced2(fl.Parent(), params[i])
+		if !found || fmt.Sprint(field.Interface()) != params[i+1] {
+			return true
+		}
+	}
+
+	return hasValue(fl.Field())
+}
+
+// hasValue reports whether the field is not nil nor zero.
+func hasValue(field reflect.Value) bool {
+	switch field.Kind() {
+	case reflect.Slice, reflect.Map, reflect.Ptr, reflect.Interface, reflect.Chan, reflect.Func:
+		return !field.IsNil()
+	}
+
+	return field.IsValid() && !field.IsZero()
+}
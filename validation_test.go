@@ -0,0 +1,161 @@
+package config
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/validator"
+)
+
+func TestRegisterValidation(t *testing.T) {
+	type config struct {
+		Version string `env:"version" validate:"semver"`
+	}
+
+	semver := regexp.MustCompile(`^v\d+\.\d+\.\d+$`)
+
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr string
+	}{
+		{name: "valid", yaml: "version: v1.2.3"},
+		{name: "invalid", yaml: "version: latest", wantErr: "field 'version' is semver"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newYAML(t, tt.yaml)
+			if err := c.RegisterValidation("semver", func(fl validator.FieldLevel) bool {
+				return semver.MatchString(fl.Field().String())
+			}); err != nil {
+				t.Fatalf("RegisterValidation() error = %v", err)
+			}
+
+			err := c.Unmarshal(&config{})
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("Unmarshal() error = %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Fatalf("Unmarshal() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	type config struct {
+		Host string `env:"host" validate:"required"`
+		Port int    `env:"port" validate:"min=1"`
+	}
+
+	c := New()
+	if err := c.Validate(&config{Host: "localhost", Port: 8080}); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	err := c.Validate(&config{Port: 8080})
+	if err == nil || !strings.Contains(err.Error(), "field 'host' is required") {
+		t.Errorf("Validate() error = %v, want the error naming host", err)
+	}
+}
+
+func TestRegisterStructValidation(t *testing.T) {
+	type window struct {
+		Start int `env:"start"`
+		End   int `env:"end"`
+	}
+	type config struct {
+		Window window `env:"window"`
+	}
+
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr bool
+	}{
+		{name: "start before end", yaml: "window:\n  start: 1\n  end: 5"},
+		{name: "start after end", yaml: "window:\n  start: 5\n  end: 1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newYAML(t, tt.yaml)
+			c.RegisterStructValidation(func(sl validator.StructLevel) {
+				w := sl.Current().Interface().(window)
+				if w.Start > w.End {
+					sl.ReportError(w.End, "end", "End", "gtefield", "start")
+				}
+			}, window{})
+
+			err := c.Unmarshal(&config{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unmarshal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr && !strings.Contains(err.Error(), "window.end") {
+				t.Errorf("Unmarshal() error = %v, want the error naming window.end", err)
+			}
+		})
+	}
+}
+
+func TestRequiredIf(t *testing.T) {
+	type config struct {
+		TLSEnabled bool   `env:"tls_enabled"`
+		CertFile   string `env:"cert_file" validate:"required_if=TLSEnabled true"`
+	}
+
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr string
+	}{
+		{name: "tls disabled", yaml: "tls_enabled: false"},
+		{name: "tls enabled with the cert", yaml: "tls_enabled: true\ncert_file: cert.pem"},
+		{name: "tls enabled without the cert", yaml: "tls_enabled: true", wantErr: "field 'cert_file' is required_if"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newYAML(t, tt.yaml).Unmarshal(&config{})
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("Unmarshal() error = %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Fatalf("Unmarshal() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDive(t *testing.T) {
+	type server struct {
+		Host string `env:"host" validate:"required"`
+	}
+	type config struct {
+		Servers []server `env:"servers" validate:"required,dive"`
+	}
+
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr string
+	}{
+		{name: "valid", yaml: "servers:\n  - host: a\n  - host: b"},
+		{name: "second server", yaml: "servers:\n  - host: a\n  - port: 80", wantErr: "validation error: field 'servers[1].host' is required"},
+		{name: "no servers", yaml: "port: 80", wantErr: "validation error: field 'servers' is required"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newYAML(t, tt.yaml).Unmarshal(&config{})
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("Unmarshal() error = %v", err)
+			case tt.wantErr != "" && (err == nil || err.Error() != "errors: "+tt.wantErr):
+				t.Fatalf("Unmarshal() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,334 @@
+package config
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Watch watches the configuration file and calls onChange every time it changes.
+// Only the last callback registered with Watch or WatchInto is called.
+func (c *Config) Watch(onChange func()) {
+	c.setOnConfigChange(func(error) {
+		onChange()
+	})
+
+	c.watch()
+}
+
+// WatchInto watches the configuration file and decodes it into config every
+// time it changes, then calls onChange with the decoding error, if any.
+// The reloads are serialized and config is only updated when the decoding
+// succeeds, so it always holds a complete configuration.
+func (c *Config) WatchInto(config interface{}, onChange func(error)) {
+	c.setOnConfigChange(func(err error) {
+		if err != nil {
+			onChange(err)
+			return
+		}
+
+		onChange(c.reload(config))
+	})
+
+	c.watch()
+}
+
+// LoadAndWatch creates a Config with the options, decodes the configuration
+// into config like Load and watches the config file like WatchInto. The
+// returned channel receives the result of every reload, nil on success, until
+// the returned stop function is called, which closes it. The reloads are
+// delivered in order, so the channel must be read for the watching to go on.
+func LoadAndWatch(config interface{}, opts ...Option) (<-chan error, func(), error) {
+	c := New(opts...)
+	if err := c.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	if err := c.Unmarshal(config); err != nil {
+		return nil, nil, err
+	}
+
+	var mu sync.Mutex
+	closed := false
+	ch := make(chan error)
+	c.WatchInto(config, func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if closed {
+			return
+		}
+
+		select {
+		case ch <- err:
+		case <-c.done:
+		}
+	})
+
+	go func() {
+		<-c.done
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		closed = true
+		close(ch)
+	}()
+
+	return ch, c.Stop, nil
+}
+
+// WatchRemote polls the remote key/value store set with WithRemoteProvider
+// every interval and, when the configuration changed, decodes it into config
+// like WatchInto, then calls onChange with the decoding error, if any. It also
+// calls onChange with the error when the remote store can't be read. The
+// polling runs until Stop is called.
+func (c *Config) WatchRemote(interval time.Duration, config interface{}, onChange func(error)) {
+	go c.poll(interval, func() {
+		changed, err := c.rereadRemote()
+		if err != nil {
+			onChange(err)
+			return
+		}
+
+		if changed {
+			onChange(c.reload(config))
+		}
+	})
+}
+
+// WatchPoll watches the configuration file by reading it every interval, for
+// the file systems not delivering the change events, e.g. network or overlay
+// file systems. When the content of the file changed it is decoded into config
+// like WatchInto, then onChange is called with the decoding error, if any. It
+// also calls onChange with the error when the file can't be read, once until
+// it is readable again. The polling runs until Stop is called.
+func (c *Config) WatchPoll(interval time.Duration, config interface{}, onChange func(error)) {
+	file := c.ConfigFileUsed()
+	if file == "" {
+		return
+	}
+
+	hash, _ := fileHash(file)
+	go c.poll(interval, func() {
+		h, err := fileHash(file)
+		if err != nil {
+			if hash != "" {
+				hash = ""
+				onChange(err)
+			}
+			return
+		}
+
+		if h == hash {
+			return
+		}
+		hash = h
+
+		if err := c.reread(); err != nil {
+			onChange(err)
+			return
+		}
+
+		onChange(c.reload(config))
+	})
+}
+
+// fileHash returns the hex encoded SHA-256 hash of the content of the file.
+func fileHash(file string) (string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// rereadRemote reads the configuration again from the remote key/value store
+// and reports whether it changed. The store is read without holding the lock,
+// so a slow store doesn't block the readers of the current settings, which are
+// only replaced if the configuration changed.
+func (c *Config) rereadRemote() (bool, error) {
+	next, err := c.next()
+	if err != nil {
+		return false, err
+	}
+
+	c.mu.RLock()
+	changed := !bytes.Equal(c.remoteData, next.remoteData)
+	c.mu.RUnlock()
+
+	if !changed {
+		return false, nil
+	}
+
+	return true, c.swap(next)
+}
+
+// next reads the configuration again into a new Config with the same options,
+// without holding the lock.
+func (c *Config) next() (*Config, error) {
+	c.mu.RLock()
+	if c.frozen {
+		c.mu.RUnlock()
+		return nil, ErrFrozen
+	}
+	next := c.copyOptions()
+	c.mu.RUnlock()
+
+	if err := next.reset(); err != nil {
+		return nil, err
+	}
+
+	return next, nil
+}
+
+// swap replaces the settings with the ones read by next, keeping the values
+// set with Set and the environment variables bound to the struct fields.
+func (c *Config) swap(next *Config) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.frozen {
+		return ErrFrozen
+	}
+
+	c.v, c.configFileUsed, c.remoteData, c.keyCases, c.err = next.v, next.configFileUsed, next.remoteData, next.keyCases, nil
+	for key, name := range c.boundEnvKeys {
+		c.v.BindEnv(key, name)
+	}
+
+	for key, value := range c.overrides {
+		c.v.Set(key, value)
+	}
+
+	return nil
+}
+
+// Stop stops watching the configuration file and the polling started with
+// WatchRemote or WatchPoll.
+func (c *Config) Stop() {
+	c.stopOnce.Do(func() {
+		close(c.done)
+	})
+}
+
+// poll calls fn every interval until Stop is called.
+func (c *Config) poll(interval time.Duration, fn func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			fn()
+		}
+	}
+}
+
+// setOnConfigChange sets the function called when the config file changes.
+func (c *Config) setOnConfigChange(onConfigChange func(error)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.onConfigChange = onConfigChange
+}
+
+// watch starts watching the configuration file, only once. The directory of
+// the file is watched, like viper does, so the editors replacing the file and
+// the symlinks updated in Kubernetes ConfigMaps are noticed.
+func (c *Config) watch() {
+	c.watchOnce.Do(func() {
+		file := c.ConfigFileUsed()
+		if file == "" {
+			return
+		}
+
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			return
+		}
+
+		file = filepath.Clean(file)
+		if err := watcher.Add(filepath.Dir(file)); err != nil {
+			watcher.Close()
+			return
+		}
+
+		go c.watchEvents(watcher, file)
+	})
+}
+
+// watchEvents reads the configuration again on every change of the file until
+// the file is removed or Stop is called.
+func (c *Config) watchEvents(watcher *fsnotify.Watcher, file string) {
+	defer watcher.Close()
+
+	realFile, _ := filepath.EvalSymlinks(file)
+	for {
+		select {
+		case <-c.done:
+			return
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+
+			currentFile, _ := filepath.EvalSymlinks(file)
+			written := filepath.Clean(event.Name) == file && (event.Has(fsnotify.Write) || event.Has(fsnotify.Create))
+			if written || (currentFile != "" && currentFile != realFile) {
+				realFile = currentFile
+				c.configChanged(c.reread())
+			} else if filepath.Clean(event.Name) == file && event.Has(fsnotify.Remove) {
+				return
+			}
+		case _, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+		}
+	}
+}
+
+// configChanged calls the function set by Watch or WatchInto, if any.
+func (c *Config) configChanged(err error) {
+	c.mu.RLock()
+	onConfigChange := c.onConfigChange
+	c.mu.RUnlock()
+
+	if onConfigChange != nil {
+		onConfigChange(err)
+	}
+}
+
+// reload decodes the configuration into a fresh value and, on success, copies
+// it into config.
+func (c *Config) reload(config interface{}) error {
+	if err := checkConfig(config); err != nil {
+		return err
+	}
+
+	c.reloadMu.Lock()
+	defer c.reloadMu.Unlock()
+
+	dst := reflect.ValueOf(config).Elem()
+	tmp := reflect.New(dst.Type())
+	if err := c.Unmarshal(tmp.Interface()); err != nil {
+		return err
+	}
+
+	dst.Set(tmp.Elem())
+
+	return nil
+}
@@ -0,0 +1,286 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeFile replaces the file with the content, failing the test on error.
+// The file is renamed into place so the watcher doesn't see it half written.
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// waitReload returns the result of the next reload, failing the test if none
+// is received in time.
+func waitReload(t *testing.T, reloads <-chan error) error {
+	t.Helper()
+
+	select {
+	case err := <-reloads:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("no reload received")
+		return nil
+	}
+}
+
+type watchConfig struct {
+	Port int    `env:"port"`
+	Host string `env:"host" validate:"required"`
+}
+
+func TestWatchInto(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeFile(t, path, "port: 8080\nhost: localhost")
+
+	c := New(WithConfigFile(path))
+	if err := c.Err(); err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer c.Stop()
+
+	var cfg watchConfig
+	if err := c.Unmarshal(&cfg); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	reloads := make(chan error, 10)
+	c.WatchInto(&cfg, func(err error) {
+		reloads <- err
+	})
+
+	writeFile(t, path, "port: 9090\nhost: example.com")
+	if err := waitReload(t, reloads); err != nil {
+		t.Fatalf("reload error = %v", err)
+	}
+	if cfg.Port != 9090 || cfg.Host != "example.com" {
+		t.Errorf("config = %+v, want port 9090 and host example.com", cfg)
+	}
+
+	// An invalid configuration is reported and the last valid one is kept
+	writeFile(t, path, "port: 7070")
+	if err := waitReload(t, reloads); err == nil {
+		t.Fatal("reload error = nil, want the validation error")
+	}
+	if cfg.Port != 9090 || cfg.Host != "example.com" {
+		t.Errorf("config = %+v, want the previous configuration", cfg)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeFile(t, path, "port: 8080")
+
+	c := New(WithConfigFile(path))
+	if err := c.Err(); err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer c.Stop()
+
+	changes := make(chan error, 10)
+	c.Watch(func() {
+		changes <- nil
+	})
+
+	writeFile(t, path, "port: 9090")
+	waitReload(t, changes)
+
+	if got := c.GetInt("port"); got != 9090 {
+		t.Errorf("GetInt() = %d, want 9090", got)
+	}
+}
+
+func TestReset(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeFile(t, path, "port: 8080")
+
+	c := New(WithConfigFile(path))
+	if err := c.Set("port", 1); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	writeFile(t, path, "port: 9090")
+	if err := c.Reset(); err != nil {
+		t.Fatalf("Reset() error = %v", err)
+	}
+
+	if got := c.GetInt("port"); got != 9090 {
+		t.Errorf("GetInt() = %d, want 9090 from the file", got)
+	}
+}
+
+func TestWatchPoll(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeFile(t, path, "port: 8080\nhost: localhost")
+
+	c := New(WithConfigFile(path))
+	if err := c.Err(); err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer c.Stop()
+
+	var cfg watchConfig
+	if err := c.Unmarshal(&cfg); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	c.Set("host", "example.com")
+
+	reloads := make(chan error, 10)
+	c.WatchPoll(10*time.Millisecond, &cfg, func(err error) {
+		reloads <- err
+	})
+
+	// The values set with Set are kept on reload
+	writeFile(t, path, "port: 9090\nhost: localhost")
+	if err := waitReload(t, reloads); err != nil {
+		t.Fatalf("reload error = %v", err)
+	}
+	if cfg.Port != 9090 || cfg.Host != "example.com" {
+		t.Errorf("config = %+v, want port 9090 and host example.com", cfg)
+	}
+
+	// The unchanged content isn't decoded again on the next ticks
+	select {
+	case err := <-reloads:
+		t.Fatalf("reload without change, error = %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// A file that can't be read is reported and the settings kept
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := waitReload(t, reloads); err == nil {
+		t.Fatal("reload error = nil, want the read error")
+	}
+	if got := c.GetInt("port"); got != 9090 {
+		t.Errorf("GetInt() = %d, want 9090", got)
+	}
+}
+
+func TestWatchRemote(t *testing.T) {
+	fetcher := &fakeFetcher{}
+	fetcher.set("port: 8080\nhost: localhost", nil)
+
+	c := New(WithRemoteFetcher(fetcher), WithFileType("yaml"))
+	if err := c.Err(); err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer c.Stop()
+
+	var cfg watchConfig
+	if err := c.Unmarshal(&cfg); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	reloads := make(chan error, 10)
+	c.WatchRemote(10*time.Millisecond, &cfg, func(err error) {
+		reloads <- err
+	})
+
+	fetcher.set("port: 9090\nhost: example.com", nil)
+	if err := waitReload(t, reloads); err != nil {
+		t.Fatalf("reload error = %v", err)
+	}
+	if cfg.Port != 9090 || cfg.Host != "example.com" {
+		t.Errorf("config = %+v, want port 9090 and host example.com", cfg)
+	}
+
+	// The fetch errors are reported and the settings kept
+	fetcher.set("", errors.New("store unavailable"))
+	if err := waitReload(t, reloads); err == nil || err.Error() != "store unavailable" {
+		t.Fatalf("reload error = %v, want the fetch error", err)
+	}
+	if got := c.GetInt("port"); got != 9090 {
+		t.Errorf("GetInt() = %d, want 9090", got)
+	}
+}
+
+func TestWatchRemoteFetchUnlocked(t *testing.T) {
+	fetcher := &fakeFetcher{}
+	fetcher.set("port: 8080\nhost: localhost", nil)
+
+	c := New(WithRemoteFetcher(fetcher), WithFileType("yaml"))
+	if err := c.Err(); err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer c.Stop()
+
+	fetcher.fetching = make(chan struct{}, 1)
+	fetcher.release = make(chan struct{})
+	defer close(fetcher.release)
+
+	var cfg watchConfig
+	c.WatchRemote(10*time.Millisecond, &cfg, func(error) {})
+
+	// While the store hangs the settings can still be read
+	<-fetcher.fetching
+	read := make(chan int)
+	go func() {
+		read <- c.GetInt("port")
+	}()
+
+	select {
+	case port := <-read:
+		if port != 8080 {
+			t.Errorf("GetInt() = %d, want 8080", port)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("GetInt() blocked by the remote fetch")
+	}
+}
+
+func TestLoadAndWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeFile(t, path, "port: 8080\nhost: localhost")
+
+	var cfg watchConfig
+	reloads, stop, err := LoadAndWatch(&cfg, WithConfigFile(path))
+	if err != nil {
+		t.Fatalf("LoadAndWatch() error = %v", err)
+	}
+	if cfg.Port != 8080 || cfg.Host != "localhost" {
+		t.Errorf("config = %+v, want port 8080 and host localhost", cfg)
+	}
+
+	writeFile(t, path, "port: 9090\nhost: example.com")
+	if err := waitReload(t, reloads); err != nil {
+		t.Fatalf("reload error = %v", err)
+	}
+	if cfg.Port != 9090 || cfg.Host != "example.com" {
+		t.Errorf("config = %+v, want port 9090 and host example.com", cfg)
+	}
+
+	stop()
+	select {
+	case _, ok := <-reloads:
+		if ok {
+			t.Error("received a reload after stop, want the channel closed")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the channel wasn't closed by stop")
+	}
+}
+
+func TestLoadAndWatchError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeFile(t, path, "port: 8080")
+
+	reloads, stop, err := LoadAndWatch(&watchConfig{}, WithConfigFile(path))
+	if err == nil || reloads != nil || stop != nil {
+		t.Errorf("LoadAndWatch() = %v, %v, want nil and the validation error", reloads, err)
+	}
+}